@@ -1,8 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
 	"sync"
+	"time"
 
 	"go.vxn.dev/dish/pkg/alert"
 	"go.vxn.dev/dish/pkg/config"
@@ -18,34 +25,179 @@ type testResults struct {
 	failedCount   int
 }
 
-// fanInChannels collects results from multiple goroutines.
-func fanInChannels(channels ...chan socket.Result) <-chan socket.Result {
-	var wg sync.WaitGroup
-	out := make(chan socket.Result)
+// errRunDeadlineExceeded is the error of sockets whose checks did not finish before the run deadline.
+var errRunDeadlineExceeded = errors.New("timed out: the check did not finish before the run deadline")
+
+// runSocketTests checks the given sockets using a pool of workers and returns the results in the order of the
+// sockets. At most cfg.Concurrency sockets are checked at once (all of them if not set) and, if cfg.HostConcurrency
+// is set, at most that many sockets of the same host. If the test of a socket fails to start, the error is logged
+// and the socket has no result.
+//
+// Once the context is done, the checks in progress are cancelled and no further checks are started. The sockets
+// whose checks did not pass by then are reported as failed with errRunDeadlineExceeded.
+func runSocketTests(ctx context.Context, sockets []socket.Socket, cfg *config.Config, logger logger.Logger) []socket.Result {
+	workers := int(cfg.Concurrency)
+	if workers == 0 || workers > len(sockets) {
+		workers = len(sockets)
+	}
+
+	var (
+		jobs    = make(chan int)
+		done    = make(chan int, len(sockets))
+		results = make([]socket.Result, len(sockets))
+		started = make([]bool, len(sockets))
+
+		wg sync.WaitGroup
+	)
 
-	// Start a goroutine for each channel
-	for _, channel := range channels {
+	for range workers {
 		wg.Add(1)
-		go func(ch <-chan socket.Result) {
+		go func() {
 			defer wg.Done()
-			for result := range ch {
-				// Forward the result to the output channel
-				out <- result
+
+			for i := range jobs {
+				result, err := checkSocket(ctx, sockets[i], cfg, logger)
+				if err != nil {
+					logger.Errorf("failed to test socket: %v", err.Error())
+				} else {
+					results[i], started[i] = result, true
+				}
+
+				done <- i
 			}
-		}(channel)
+		}()
 	}
 
-	// Close the output channel once all workers are done
-	go func() {
-		wg.Wait()
-		close(out)
-	}()
+	dispatchSockets(sockets, workers, int(cfg.HostConcurrency), jobs, done)
+	close(jobs)
+
+	wg.Wait()
+
+	var out []socket.Result
+	for i, result := range results {
+		if started[i] {
+			out = append(out, result)
+		}
+	}
 
 	return out
 }
 
+// hostQueue holds the sockets of a host waiting to be checked and the number of its sockets being checked.
+type hostQueue struct {
+	pending []int
+	active  int
+}
+
+// dispatchSockets hands the indexes of the given sockets over to the workers through jobs, at most workers of
+// them at a time. The workers report each finished socket through done, which must be buffered for all sockets.
+//
+// If hostConcurrency is set, a socket is only handed over once fewer than hostConcurrency sockets of its host are
+// being checked, so that the workers are not held by sockets waiting for their host while sockets of other hosts
+// could be checked. The sockets of each host are handed over in order. The function returns once all sockets have
+// been handed over.
+func dispatchSockets(sockets []socket.Socket, workers, hostConcurrency int, jobs chan<- int, done <-chan int) {
+	// Without a host limit, all sockets share a single queue, so that they are handed over in order.
+	queueKey := func(sock socket.Socket) string {
+		if hostConcurrency == 0 {
+			return ""
+		}
+		return hostKey(sock)
+	}
+
+	var (
+		queues = make(map[string]*hostQueue)
+		order  []*hostQueue
+	)
+
+	for i, sock := range sockets {
+		queue := queues[queueKey(sock)]
+		if queue == nil {
+			queue = &hostQueue{}
+			queues[queueKey(sock)] = queue
+			order = append(order, queue)
+		}
+		queue.pending = append(queue.pending, i)
+	}
+
+	for running, remaining := 0, len(sockets); remaining > 0; {
+		// Pick the first pending socket of a host with a free slot.
+		var next *hostQueue
+		if running < workers {
+			for _, queue := range order {
+				if len(queue.pending) == 0 || (hostConcurrency > 0 && queue.active >= hostConcurrency) {
+					continue
+				}
+				if next == nil || queue.pending[0] < next.pending[0] {
+					next = queue
+				}
+			}
+		}
+
+		// Wait for a check to finish if no worker or no host slot is free.
+		if next == nil {
+			queues[queueKey(sockets[<-done])].active--
+			running--
+			continue
+		}
+
+		i := next.pending[0]
+		next.pending = next.pending[1:]
+		next.active++
+		running++
+		remaining--
+
+		jobs <- i
+	}
+}
+
+// checkSocket checks the given socket. If the context is done before the check starts or the check fails because
+// the context is done while it is in progress, the socket is reported as failed with errRunDeadlineExceeded. A check
+// which failed on its own is reported with its own error, even if the context is done by the time it returns.
+func checkSocket(ctx context.Context, sock socket.Socket, cfg *config.Config, logger logger.Logger) (socket.Result, error) {
+	if ctx.Err() != nil {
+		return socket.Result{Socket: sock, Error: errRunDeadlineExceeded}, nil
+	}
+
+	result, err := netrunner.RunSocket(ctx, sock, cfg, logger)
+	if err == nil && !result.Passed && cancelledBy(ctx, result.Error) {
+		result.Error = fmt.Errorf("%w (%v)", errRunDeadlineExceeded, result.Error)
+	}
+
+	return result, err
+}
+
+// cancelledBy reports whether the given error of a check was caused by the context being done, either directly
+// or through an I/O deadline set from the context.
+func cancelledBy(ctx context.Context, err error) bool {
+	if ctx.Err() == nil || err == nil {
+		return false
+	}
+
+	var netErr net.Error
+
+	return errors.Is(err, ctx.Err()) || errors.Is(err, os.ErrDeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// hostKey returns the host of the given socket used to limit the number of concurrent checks per host.
+func hostKey(sock socket.Socket) string {
+	if u, err := url.Parse(sock.Host); err == nil && u.Hostname() != "" {
+		return strings.ToLower(u.Hostname())
+	}
+
+	return strings.ToLower(sock.Host)
+}
+
 // runTests orchestrates the process of checking of a list of sockets. It fetches the socket list, runs socket checks, collects results and returns them.
+// If a run deadline is set, the checks which do not finish before it passes are reported as timed out.
 func runTests(cfg *config.Config, logger logger.Logger) (*testResults, error) {
+	ctx := context.Background()
+	if cfg.RunDeadlineSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(cfg.RunDeadlineSeconds)*time.Second)
+		defer cancel()
+	}
+
 	// Load socket list to run tests on
 	list, err := socket.FetchSocketList(cfg, logger)
 	if err != nil {
@@ -59,33 +211,18 @@ func runTests(cfg *config.Config, logger logger.Logger) (*testResults, error) {
 
 	testResults := &testResults{
 		messengerText: "",
-		results:       &alert.Results{Map: make(map[string]bool)},
-		failedCount:   0,
+		results: &alert.Results{
+			Map:           make(map[string]bool),
+			ResponseTimes: make(map[string]int64),
+			HTTPTimings:   make(map[string]alert.HTTPTimings),
+		},
+		failedCount: 0,
 	}
 
-	var (
-		// A slice of channels needs to be used here so that each goroutine has its own channel which it then closes upon performing the socket check. One shared channel for all goroutines would not work as it would not be clear which goroutine should close the channel.
-		channels = make([](chan socket.Result), len(list.Sockets))
-
-		wg sync.WaitGroup
-		i  int
-	)
-
-	// Start goroutines for each socket test
-	for _, sock := range list.Sockets {
-		wg.Add(1)
-		channels[i] = make(chan socket.Result)
-
-		go netrunner.RunSocketTest(sock, channels[i], &wg, cfg, logger)
-		i++
-	}
-
-	// Merge channels into one
-	results := fanInChannels(channels...)
-	wg.Wait()
+	results := runSocketTests(ctx, list.Sockets, cfg, logger)
 
 	// Collect results
-	for result := range results {
+	for _, result := range results {
 		if !result.Passed || result.Error != nil {
 			testResults.failedCount++
 		}
@@ -93,6 +230,15 @@ func runTests(cfg *config.Config, logger logger.Logger) (*testResults, error) {
 			testResults.messengerText += alert.FormatMessengerText(result)
 		}
 		testResults.results.Map[result.Socket.ID] = result.Passed
+		testResults.results.ResponseTimes[result.Socket.ID] = result.Duration.Milliseconds()
+		if t := result.HTTPTimings; t != nil {
+			testResults.results.HTTPTimings[result.Socket.ID] = alert.HTTPTimings{
+				DNSLookupMs:       t.DNSLookup.Milliseconds(),
+				TCPConnectMs:      t.TCPConnect.Milliseconds(),
+				TLSHandshakeMs:    t.TLSHandshake.Milliseconds(),
+				TimeToFirstByteMs: t.TimeToFirstByte.Milliseconds(),
+			}
+		}
 	}
 
 	return testResults, nil
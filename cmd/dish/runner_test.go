@@ -1,74 +1,277 @@
 package main
 
 import (
-	"reflect"
-	"sort"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"go.vxn.dev/dish/pkg/config"
+	"go.vxn.dev/dish/pkg/logger"
 	"go.vxn.dev/dish/pkg/socket"
 )
 
-// compareResults is a custom comparison function to assert the results returned from the fanInChannels function are equal to the expected results
-func compareResults(expected, actual []socket.Result) bool {
-	sort.Slice(expected, func(i, j int) bool {
-		return expected[i].ResponseCode < expected[j].ResponseCode
-	})
-	sort.Slice(actual, func(i, j int) bool {
-		return actual[i].ResponseCode < actual[j].ResponseCode
-	})
-
-	for i := range expected {
-		if !reflect.DeepEqual(expected[i].Socket, actual[i].Socket) ||
-			expected[i].Passed != actual[i].Passed ||
-			expected[i].ResponseCode != actual[i].ResponseCode {
-			return false
-		}
-	}
-	return true
+// slowServer is a TCP server which replies "OK" to every connection after a delay and records the
+// maximum number of connections it handled at once.
+type slowServer struct {
+	port      int
+	active    atomic.Int32
+	maxActive atomic.Int32
 }
 
-func TestFanInChannels(t *testing.T) {
-	testChannels := []chan socket.Result{}
+// newSlowServer starts a slowServer on a random local port. The server is closed when the test finishes.
+func newSlowServer(t *testing.T, delay time.Duration) *slowServer {
+	t.Helper()
 
-	for range 3 {
-		c := make(chan socket.Result)
-		testChannels = append(testChannels, c)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start a TCP listener: %v", err)
 	}
 
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+
+	server := &slowServer{port: ln.Addr().(*net.TCPAddr).Port}
+
 	go func() {
-		for i, channel := range testChannels {
-			channel <- socket.Result{
-				Socket:       socket.Socket{},
-				Passed:       true,
-				ResponseCode: 200 + i,
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
 			}
-			close(channel)
+
+			go func() {
+				defer conn.Close() //nolint:errcheck
+
+				active := server.active.Add(1)
+
+				for {
+					current := server.maxActive.Load()
+					if active <= current || server.maxActive.CompareAndSwap(current, active) {
+						break
+					}
+				}
+
+				time.Sleep(delay)
+
+				// The connection stops being active before the reply is sent, so that the next check started
+				// once the client reads it is not counted together with this one.
+				server.active.Add(-1)
+				_, _ = conn.Write([]byte("OK\n"))
+			}()
 		}
 	}()
 
-	resultingChan := fanInChannels(testChannels...)
-	actual := []socket.Result{}
-	for result := range resultingChan {
-		actual = append(actual, result)
+	return server
+}
+
+// sockets returns n sockets checking the server using the given host.
+func (s *slowServer) sockets(host string, n int) []socket.Socket {
+	sockets := make([]socket.Socket, n)
+	for i := range sockets {
+		sockets[i] = socket.Socket{ID: host + "_" + strconv.Itoa(i), Host: host, Port: s.port, TCPExpect: "OK"}
 	}
 
-	expected := []socket.Result{
+	return sockets
+}
+
+func TestRunSocketTests(t *testing.T) {
+	tests := []struct {
+		name            string
+		concurrency     uint
+		hostConcurrency uint
+		wantMaxActive   int32
+	}{
+		{
+			name:          "checks all sockets at once without a limit",
+			wantMaxActive: 6,
+		},
+		{
+			name:          "limits the number of sockets checked at once",
+			concurrency:   2,
+			wantMaxActive: 2,
+		},
 		{
-			Socket:       socket.Socket{},
-			Passed:       true,
-			ResponseCode: 200,
-		}, {
-			Socket:       socket.Socket{},
-			Passed:       true,
-			ResponseCode: 201,
-		}, {
-			Socket:       socket.Socket{},
-			Passed:       true,
-			ResponseCode: 202,
+			name:            "limits the number of sockets of the same host checked at once",
+			hostConcurrency: 1,
+			wantMaxActive:   2,
 		},
 	}
 
-	if !compareResults(expected, actual) {
-		t.Fatalf("expected: %+v, got: %+v", expected, actual)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newSlowServer(t, 50*time.Millisecond)
+
+			sockets := append(server.sockets("127.0.0.1", 3), server.sockets("localhost", 3)...)
+			cfg := &config.Config{TimeoutSeconds: 5, Concurrency: tt.concurrency, HostConcurrency: tt.hostConcurrency}
+
+			results := runSocketTests(context.Background(), sockets, cfg, logger.NewConsoleLogger(false, io.Discard))
+
+			if len(results) != len(sockets) {
+				t.Fatalf("expected %d results, got %d", len(sockets), len(results))
+			}
+
+			for i, result := range results {
+				if result.Socket.ID != sockets[i].ID {
+					t.Errorf("expected result %d to belong to socket %s, got %s", i, sockets[i].ID, result.Socket.ID)
+				}
+				if !result.Passed {
+					t.Errorf("expected socket %s to pass, got error: %v", result.Socket.ID, result.Error)
+				}
+			}
+
+			if got := server.maxActive.Load(); got > tt.wantMaxActive {
+				t.Errorf("expected at most %d sockets checked at once, got %d", tt.wantMaxActive, got)
+			}
+		})
+	}
+}
+
+func TestRunSocketTests_FailedToStart(t *testing.T) {
+	sockets := []socket.Socket{
+		{ID: "no_host"},
+		{ID: "no_runner", Host: "example.com", Protocol: "gopher"},
+	}
+
+	results := runSocketTests(context.Background(), sockets, &config.Config{TimeoutSeconds: 1}, logger.NewConsoleLogger(false, io.Discard))
+
+	if len(results) != 0 {
+		t.Errorf("expected no results for sockets which cannot be tested, got %+v", results)
+	}
+}
+
+func TestRunSocketTests_RunDeadline(t *testing.T) {
+	server := newSlowServer(t, 300*time.Millisecond)
+
+	// With one socket checked at a time, the first check is in progress and the second one has not started yet
+	// when the deadline passes.
+	sockets := server.sockets("127.0.0.1", 2)
+	cfg := &config.Config{TimeoutSeconds: 5, Concurrency: 1}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	results := runSocketTests(ctx, sockets, cfg, logger.NewConsoleLogger(false, io.Discard))
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the checks to be cancelled at the deadline, took %v", elapsed)
+	}
+
+	if len(results) != len(sockets) {
+		t.Fatalf("expected partial results for all %d sockets, got %d", len(sockets), len(results))
+	}
+
+	for _, result := range results {
+		if result.Passed || !errors.Is(result.Error, errRunDeadlineExceeded) {
+			t.Errorf("expected socket %s to be reported as timed out, got passed = %v, error = %v", result.Socket.ID, result.Passed, result.Error)
+		}
+	}
+}
+
+func TestRunSocketTests_FailedBeforeRunDeadline(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start a TCP listener: %v", err)
+	}
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	_ = ln.Close()
+
+	// The connection is refused right away and the deadline passes during the backoff before the retry, so the
+	// check returns after the deadline with its own error.
+	retries, backoff := 1, 5000
+	sockets := []socket.Socket{{ID: "refused", Host: "127.0.0.1", Port: port, Retries: &retries, RetryBackoffMs: &backoff}}
+	cfg := &config.Config{TimeoutSeconds: 5}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	results := runSocketTests(ctx, sockets, cfg, logger.NewConsoleLogger(false, io.Discard))
+
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+
+	if result := results[0]; result.Passed || result.Error == nil || errors.Is(result.Error, errRunDeadlineExceeded) {
+		t.Errorf("expected socket %s to fail with its own error, got passed = %v, error = %v", result.Socket.ID, result.Passed, result.Error)
+	}
+}
+
+func TestCancelledBy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if cancelledBy(ctx, context.Canceled) {
+		t.Errorf("cancelledBy(): expected false while the context is not done")
+	}
+
+	cancel()
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "context error", err: fmt.Errorf("request failed: %w", context.Canceled), want: true},
+		{name: "I/O deadline", err: fmt.Errorf("expected reply not received: %w", os.ErrDeadlineExceeded), want: true},
+		{name: "connection refused", err: errors.New("connect: connection refused")},
+		{name: "no error"},
+	}
+
+	for _, tt := range tests {
+		if got := cancelledBy(ctx, tt.err); got != tt.want {
+			t.Errorf("cancelledBy(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRunSocketTests_HostConcurrencyDoesNotBlockOtherHosts(t *testing.T) {
+	server := newSlowServer(t, 200*time.Millisecond)
+
+	// The sockets of the first host can only be checked one at a time, so the second worker has to check the
+	// socket of the second host meanwhile instead of waiting for the first host.
+	sockets := append(server.sockets("127.0.0.1", 3), server.sockets("localhost", 1)...)
+	cfg := &config.Config{TimeoutSeconds: 5, Concurrency: 2, HostConcurrency: 1}
+
+	// The deadline leaves time for a single check of each host only.
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	results := runSocketTests(ctx, sockets, cfg, logger.NewConsoleLogger(false, io.Discard))
+
+	if len(results) != len(sockets) {
+		t.Fatalf("expected %d results, got %d", len(sockets), len(results))
+	}
+
+	for _, i := range []int{0, 3} {
+		if !results[i].Passed {
+			t.Errorf("expected socket %s to be checked before the deadline, got error: %v", results[i].Socket.ID, results[i].Error)
+		}
+	}
+}
+
+func TestHostKey(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{host: "https://Example.com", want: "example.com"},
+		{host: "http://example.com:8080/health", want: "example.com"},
+		{host: "https://[::1]", want: "::1"},
+		{host: "Example.com", want: "example.com"},
+		{host: "192.0.2.1", want: "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		if got := hostKey(socket.Socket{Host: tt.host}); got != tt.want {
+			t.Errorf("hostKey(%q) = %s, want %s", tt.host, got, tt.want)
+		}
 	}
 }
@@ -18,6 +18,10 @@ func FormatMessengerText(result socket.Result) string {
 		text += result.Socket.PathHTTP
 	}
 
+	if result.Duration > 0 {
+		text += fmt.Sprintf(" (%dms)", result.Duration.Milliseconds())
+	}
+
 	text += " -- " + status
 
 	if status == "failed" {
@@ -28,6 +32,10 @@ func FormatMessengerText(result socket.Result) string {
 		text += " \u2705" // ✅
 	}
 
+	if result.Attempts > 1 {
+		text += fmt.Sprintf(" (%d attempts)", result.Attempts)
+	}
+
 	text += "\n"
 
 	return text
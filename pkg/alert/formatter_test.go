@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"go.vxn.dev/dish/pkg/socket"
 )
@@ -91,6 +92,37 @@ func TestFormatMessengerText(t *testing.T) {
 			},
 			expectedText: "• https://test.testdomain.xyz:80/ -- failed ❌ -- expected codes: [200], got 500\n",
 		},
+		{
+			name: "Passed HTTP Check with Duration",
+			result: socket.Result{
+				Socket: socket.Socket{
+					ID:                "test_socket",
+					Name:              "test socket",
+					Host:              "https://test.testdomain.xyz",
+					Port:              443,
+					ExpectedHTTPCodes: []int{200},
+					PathHTTP:          "/",
+				},
+				Passed:   true,
+				Duration: 123 * time.Millisecond,
+			},
+			expectedText: "• https://test.testdomain.xyz:443/ (123ms) -- success ✅\n",
+		},
+		{
+			name: "Failed TCP Check after Retries",
+			result: socket.Result{
+				Socket: socket.Socket{
+					ID:   "test_socket",
+					Name: "test socket",
+					Host: "test.testdomain.xyz",
+					Port: 5432,
+				},
+				Passed:   false,
+				Error:    errors.New("connection refused"),
+				Attempts: 3,
+			},
+			expectedText: "• test.testdomain.xyz:5432 -- failed ❌ -- connection refused (3 attempts)\n",
+		},
 	}
 
 	for _, tt := range tests {
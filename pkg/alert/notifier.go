@@ -11,6 +11,20 @@ import (
 
 type Results struct {
 	Map map[string]bool `json:"dish_results"`
+
+	// ResponseTimes holds the duration of each socket check in milliseconds, keyed by the socket ID.
+	ResponseTimes map[string]int64 `json:"dish_response_times_ms,omitempty"`
+
+	// HTTPTimings holds the durations of the individual phases of HTTP checks, keyed by the socket ID.
+	HTTPTimings map[string]HTTPTimings `json:"dish_http_timings,omitempty"`
+}
+
+// HTTPTimings holds the durations of the individual phases of a HTTP check in milliseconds.
+type HTTPTimings struct {
+	DNSLookupMs       int64 `json:"dns_lookup_ms"`
+	TCPConnectMs      int64 `json:"tcp_connect_ms"`
+	TLSHandshakeMs    int64 `json:"tls_handshake_ms"`
+	TimeToFirstByteMs int64 `json:"time_to_first_byte_ms"`
 }
 
 type ChatNotifier interface {
@@ -20,12 +20,25 @@ var messageTemplate = `
 #HELP failed sockets registered by dish
 #TYPE dish_failed_count counter
 dish_failed_count {{ .FailedCount }}
-
+{{ if .ResponseTimes }}
+#HELP response times of sockets checked by dish in milliseconds
+#TYPE dish_response_time_ms gauge
+{{ range $id, $ms := .ResponseTimes }}dish_response_time_ms{socket={{ printf "%q" $id }}} {{ $ms }}
+{{ end }}{{ end }}{{ if .HTTPTimings }}
+#HELP durations of the phases of HTTP checks performed by dish in milliseconds
+#TYPE dish_http_phase_ms gauge
+{{ range $id, $t := .HTTPTimings }}dish_http_phase_ms{socket={{ printf "%q" $id }},phase="dns_lookup"} {{ $t.DNSLookupMs }}
+dish_http_phase_ms{socket={{ printf "%q" $id }},phase="tcp_connect"} {{ $t.TCPConnectMs }}
+dish_http_phase_ms{socket={{ printf "%q" $id }},phase="tls_handshake"} {{ $t.TLSHandshakeMs }}
+dish_http_phase_ms{socket={{ printf "%q" $id }},phase="time_to_first_byte"} {{ $t.TimeToFirstByteMs }}
+{{ end }}{{ end }}
 `
 
 // messageData is a struct used to store Pushgateway message template variables.
 type messageData struct {
-	FailedCount int
+	FailedCount   int
+	ResponseTimes map[string]int64
+	HTTPTimings   map[string]HTTPTimings
 }
 
 type pushgatewaySender struct {
@@ -63,10 +76,16 @@ func NewPushgatewaySender(httpClient HTTPClient, config *config.Config, logger l
 }
 
 // createMessage returns a string containing the message text in Pushgateway-specific format.
-func (s *pushgatewaySender) createMessage(failedCount int) (string, error) {
+func (s *pushgatewaySender) createMessage(results *Results, failedCount int) (string, error) {
 	var buf bytes.Buffer
 
-	err := s.tmpl.Execute(&buf, messageData{FailedCount: failedCount})
+	data := messageData{FailedCount: failedCount}
+	if results != nil {
+		data.ResponseTimes = results.ResponseTimes
+		data.HTTPTimings = results.HTTPTimings
+	}
+
+	err := s.tmpl.Execute(&buf, data)
 	if err != nil {
 		return "", fmt.Errorf("error executing Pushgateway message template: %w", err)
 	}
@@ -76,8 +95,8 @@ func (s *pushgatewaySender) createMessage(failedCount int) (string, error) {
 
 // Send pushes the results to Pushgateway.
 //
-// The first argument is needed to implement the MachineNotifier interface, however, it is ignored in favor of a custom message implementation via the createMessage method.
-func (s *pushgatewaySender) send(_ *Results, failedCount int) error {
+// The results are not pushed as JSON, a custom message implementation in Prometheus text format is created via the createMessage method instead.
+func (s *pushgatewaySender) send(results *Results, failedCount int) error {
 	// If no checks failed and success should not be notified, there is nothing to send
 	if failedCount == 0 && !s.notifySuccess {
 		s.logger.Debug("no sockets failed, nothing will be sent to Pushgateway")
@@ -85,7 +104,7 @@ func (s *pushgatewaySender) send(_ *Results, failedCount int) error {
 		return nil
 	}
 
-	msg, err := s.createMessage(failedCount)
+	msg, err := s.createMessage(results, failedCount)
 	if err != nil {
 		return err
 	}
@@ -249,7 +249,55 @@ dish_failed_count 1
 
 `
 
-	actual, err := sender.createMessage(failedCount)
+	actual, err := sender.createMessage(nil, failedCount)
+	if err != nil {
+		t.Errorf("error creating Pushgateway message: %v", err)
+	}
+
+	if expected != actual {
+		t.Errorf("expected %s, got %s", expected, actual)
+	}
+}
+
+func TestCreateMessage_Timings(t *testing.T) {
+	cfg := &config.Config{
+		PushgatewayURL: pushgatewayURL,
+		InstanceName:   "test-instance",
+	}
+
+	sender, err := NewPushgatewaySender(&SuccessStatusHTTPClient{}, cfg, &MockLogger{})
+	if err != nil {
+		t.Fatalf("failed to create Pushgateway sender instance: %v", err)
+	}
+
+	results := &Results{
+		Map:           map[string]bool{"web": true, "db": false},
+		ResponseTimes: map[string]int64{"web": 120, "db": 3001},
+		HTTPTimings: map[string]HTTPTimings{
+			"web": {DNSLookupMs: 5, TCPConnectMs: 10, TLSHandshakeMs: 30, TimeToFirstByteMs: 110},
+		},
+	}
+
+	expected := `
+#HELP failed sockets registered by dish
+#TYPE dish_failed_count counter
+dish_failed_count 1
+
+#HELP response times of sockets checked by dish in milliseconds
+#TYPE dish_response_time_ms gauge
+dish_response_time_ms{socket="db"} 3001
+dish_response_time_ms{socket="web"} 120
+
+#HELP durations of the phases of HTTP checks performed by dish in milliseconds
+#TYPE dish_http_phase_ms gauge
+dish_http_phase_ms{socket="web",phase="dns_lookup"} 5
+dish_http_phase_ms{socket="web",phase="tcp_connect"} 10
+dish_http_phase_ms{socket="web",phase="tls_handshake"} 30
+dish_http_phase_ms{socket="web",phase="time_to_first_byte"} 110
+
+`
+
+	actual, err := sender.createMessage(results, 1)
 	if err != nil {
 		t.Errorf("error creating Pushgateway message: %v", err)
 	}
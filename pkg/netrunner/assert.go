@@ -0,0 +1,261 @@
+package netrunner
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+// defaultMaxBodyBytes is the maximum number of bytes of a HTTP response body read for assertions if not set otherwise.
+const defaultMaxBodyBytes = 1 << 20
+
+// httpExpectations holds the assertions evaluated on a HTTP response.
+type httpExpectations struct {
+	codes           []int
+	bodyContains    string
+	bodyNotContains string
+	bodyRegex       string
+	json            []socket.JSONAssertion
+	headers         []socket.HeaderAssertion
+	maxBodyBytes    int64
+	finalURL        string
+	location        string
+}
+
+// expectationsFromSocket returns the HTTP response assertions configured for the given socket.
+func expectationsFromSocket(sock socket.Socket) httpExpectations {
+	return httpExpectations{
+		codes:           sock.ExpectedHTTPCodes,
+		bodyContains:    sock.ExpectedBodyContains,
+		bodyNotContains: sock.ExpectedBodyNotContains,
+		bodyRegex:       sock.ExpectedBodyRegex,
+		json:            sock.ExpectedJSON,
+		headers:         sock.ExpectedHeaders,
+		maxBodyBytes:    sock.MaxBodyBytes,
+		finalURL:        sock.ExpectedFinalURL,
+		location:        sock.ExpectedLocation,
+	}
+}
+
+// needsBody reports whether the response body has to be read to evaluate the assertions.
+func (e httpExpectations) needsBody() bool {
+	return e.bodyContains != "" || e.bodyNotContains != "" || e.bodyRegex != "" || len(e.json) > 0
+}
+
+// check evaluates all assertions on the provided response: the status code first, then the final URL and
+// the Location header, the headers and finally the body, which is only read if any body assertions are configured.
+func (e httpExpectations) check(resp *http.Response) error {
+	if err := e.checkCode(resp.StatusCode); err != nil {
+		return err
+	}
+
+	if err := e.checkRedirect(resp); err != nil {
+		return err
+	}
+
+	if err := e.checkHeaders(resp.Header); err != nil {
+		return err
+	}
+
+	if !e.needsBody() {
+		return nil
+	}
+
+	body, err := e.readBody(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	return e.checkBody(body)
+}
+
+// checkCode returns an error if the provided response status code is not one of the expected codes.
+func (e httpExpectations) checkCode(code int) error {
+	if !slices.Contains(e.codes, code) {
+		return fmt.Errorf("expected codes: %v, got %d", e.codes, code)
+	}
+
+	return nil
+}
+
+// checkRedirect compares the URL the request ended at after following redirects and the Location header of the
+// response with the expected ones, if set. A relative location is resolved against the URL of the request.
+func (e httpExpectations) checkRedirect(resp *http.Response) error {
+	if e.finalURL != "" {
+		want, err := url.Parse(e.finalURL)
+		if err != nil {
+			return fmt.Errorf("invalid expected final URL: %w", err)
+		}
+
+		if got := resp.Request.URL; !sameURL(got, want) {
+			return fmt.Errorf("expected final URL %q, got %q", e.finalURL, got)
+		}
+	}
+
+	if e.location != "" {
+		got, err := resp.Location()
+		if errors.Is(err, http.ErrNoLocation) {
+			return fmt.Errorf("expected Location header %q, got none", e.location)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid Location header: %w", err)
+		}
+
+		want, err := resp.Request.URL.Parse(e.location)
+		if err != nil {
+			return fmt.Errorf("invalid expected location: %w", err)
+		}
+
+		if !sameURL(got, want) {
+			return fmt.Errorf("expected Location header %q, got %q", e.location, resp.Header.Get("Location"))
+		}
+	}
+
+	return nil
+}
+
+// checkHeaders evaluates the header assertions on the provided response headers and returns a descriptive
+// error for the first assertion which does not hold.
+func (e httpExpectations) checkHeaders(header http.Header) error {
+	for _, assertion := range e.headers {
+		values, ok := header[http.CanonicalHeaderKey(assertion.Name)]
+		if !ok {
+			return fmt.Errorf("expected response header %q to be present", assertion.Name)
+		}
+
+		value := strings.Join(values, ", ")
+
+		if assertion.Equals != "" && value != assertion.Equals {
+			return fmt.Errorf("expected response header %q to equal %q, got %q", assertion.Name, assertion.Equals, value)
+		}
+
+		if assertion.Regex != "" {
+			exp, err := regexp.Compile(assertion.Regex)
+			if err != nil {
+				return fmt.Errorf("invalid regex for header %q: %w", assertion.Name, err)
+			}
+
+			if !exp.MatchString(value) {
+				return fmt.Errorf("expected response header %q to match %q, got %q", assertion.Name, assertion.Regex, value)
+			}
+		}
+	}
+
+	return nil
+}
+
+// readBody reads the response body up to the configured size limit. Any bytes over the limit are ignored.
+func (e httpExpectations) readBody(body io.Reader) ([]byte, error) {
+	limit := e.maxBodyBytes
+	if limit <= 0 {
+		limit = defaultMaxBodyBytes
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	return data, nil
+}
+
+// checkBody evaluates the body assertions on the provided response body and returns a descriptive error
+// for the first assertion which does not hold.
+func (e httpExpectations) checkBody(body []byte) error {
+	if e.bodyContains != "" && !bytes.Contains(body, []byte(e.bodyContains)) {
+		return fmt.Errorf("expected response body to contain %q", e.bodyContains)
+	}
+
+	if e.bodyNotContains != "" && bytes.Contains(body, []byte(e.bodyNotContains)) {
+		return fmt.Errorf("expected response body not to contain %q", e.bodyNotContains)
+	}
+
+	if e.bodyRegex != "" {
+		exp, err := regexp.Compile(e.bodyRegex)
+		if err != nil {
+			return fmt.Errorf("invalid body regex: %w", err)
+		}
+
+		if !exp.Match(body) {
+			return fmt.Errorf("expected response body to match %q", e.bodyRegex)
+		}
+	}
+
+	if len(e.json) > 0 {
+		return checkJSON(body, e.json)
+	}
+
+	return nil
+}
+
+// checkJSON parses the provided body as JSON and evaluates the given assertions on it.
+func checkJSON(body []byte, assertions []socket.JSONAssertion) error {
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return fmt.Errorf("failed to parse response body as JSON: %w", err)
+	}
+
+	for _, assertion := range assertions {
+		got, err := lookupJSONPath(doc, assertion.Path)
+		if err != nil {
+			return err
+		}
+
+		if len(assertion.Equals) == 0 {
+			continue
+		}
+
+		var want any
+		if err := json.Unmarshal(assertion.Equals, &want); err != nil {
+			return fmt.Errorf("invalid expected JSON value for path %q: %w", assertion.Path, err)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			gotJSON, _ := json.Marshal(got)
+			return fmt.Errorf("expected JSON path %q to equal %s, got %s", assertion.Path, assertion.Equals, gotJSON)
+		}
+	}
+
+	return nil
+}
+
+// lookupJSONPath returns the value found at the given dot-separated path in the decoded JSON document.
+// Object members are addressed by their name, array elements by their index.
+func lookupJSONPath(doc any, path string) (any, error) {
+	if path == "" {
+		return doc, nil
+	}
+
+	current := doc
+	for _, key := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]any:
+			value, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("JSON path %q not found", path)
+			}
+			current = value
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("JSON path %q not found", path)
+			}
+			current = node[i]
+		default:
+			return nil, fmt.Errorf("JSON path %q not found", path)
+		}
+	}
+
+	return current, nil
+}
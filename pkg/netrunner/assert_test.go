@@ -0,0 +1,221 @@
+package netrunner
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+func TestHttpExpectations_CheckBody(t *testing.T) {
+	body := []byte(`<html><body>Service status: operational</body></html>`)
+
+	tests := []struct {
+		name    string
+		exp     httpExpectations
+		wantErr bool
+	}{
+		{
+			name: "passes with no assertions",
+			exp:  httpExpectations{},
+		},
+		{
+			name: "passes when the body contains the expected string",
+			exp:  httpExpectations{bodyContains: "operational"},
+		},
+		{
+			name:    "fails when the body does not contain the expected string",
+			exp:     httpExpectations{bodyContains: "healthy"},
+			wantErr: true,
+		},
+		{
+			name: "passes when the body does not contain the forbidden string",
+			exp:  httpExpectations{bodyNotContains: "maintenance"},
+		},
+		{
+			name:    "fails when the body contains the forbidden string",
+			exp:     httpExpectations{bodyNotContains: "operational"},
+			wantErr: true,
+		},
+		{
+			name: "passes when the body matches the regex",
+			exp:  httpExpectations{bodyRegex: `status: (operational|degraded)`},
+		},
+		{
+			name:    "fails when the body does not match the regex",
+			exp:     httpExpectations{bodyRegex: `status: down`},
+			wantErr: true,
+		},
+		{
+			name:    "fails on an invalid regex",
+			exp:     httpExpectations{bodyRegex: `(`},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.exp.checkBody(body); (err != nil) != tt.wantErr {
+				t.Fatalf("checkBody(): error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckJSON(t *testing.T) {
+	body := []byte(`{"status": "ok", "uptime": 42, "checks": {"db": {"healthy": false}}, "nodes": [{"name": "a"}, {"name": "b"}]}`)
+
+	tests := []struct {
+		name       string
+		assertions []socket.JSONAssertion
+		wantErr    bool
+	}{
+		{
+			name:       "passes on a matching string value",
+			assertions: []socket.JSONAssertion{{Path: "status", Equals: json.RawMessage(`"ok"`)}},
+		},
+		{
+			name:       "passes on a matching number value",
+			assertions: []socket.JSONAssertion{{Path: "uptime", Equals: json.RawMessage(`42`)}},
+		},
+		{
+			name:       "passes on a matching array element",
+			assertions: []socket.JSONAssertion{{Path: "nodes.1.name", Equals: json.RawMessage(`"b"`)}},
+		},
+		{
+			name:       "passes when only the presence of a path is checked",
+			assertions: []socket.JSONAssertion{{Path: "checks.db"}},
+		},
+		{
+			name:       "fails on a nested value mismatch",
+			assertions: []socket.JSONAssertion{{Path: "checks.db.healthy", Equals: json.RawMessage(`true`)}},
+			wantErr:    true,
+		},
+		{
+			name:       "fails on a missing path",
+			assertions: []socket.JSONAssertion{{Path: "checks.cache.healthy"}},
+			wantErr:    true,
+		},
+		{
+			name:       "fails on an out of range array index",
+			assertions: []socket.JSONAssertion{{Path: "nodes.2.name"}},
+			wantErr:    true,
+		},
+		{
+			name:       "fails on an invalid expected value",
+			assertions: []socket.JSONAssertion{{Path: "status", Equals: json.RawMessage(`ok`)}},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkJSON(body, tt.assertions); (err != nil) != tt.wantErr {
+				t.Fatalf("checkJSON(): error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("fails on a body which is not JSON", func(t *testing.T) {
+		if err := checkJSON([]byte("<html>"), []socket.JSONAssertion{{Path: "status"}}); err == nil {
+			t.Fatal("checkJSON(): expected an error, got nil")
+		}
+	})
+}
+
+func TestHttpExpectations_CheckHeaders(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	header.Set("Strict-Transport-Security", "max-age=63072000")
+	header.Set("X-Version", "1.4.2")
+
+	tests := []struct {
+		name       string
+		assertions []socket.HeaderAssertion
+		wantErr    bool
+	}{
+		{
+			name:       "passes when the header is present",
+			assertions: []socket.HeaderAssertion{{Name: "strict-transport-security"}},
+		},
+		{
+			name:       "passes when the header equals the expected value",
+			assertions: []socket.HeaderAssertion{{Name: "X-Version", Equals: "1.4.2"}},
+		},
+		{
+			name:       "passes when the header matches the regex",
+			assertions: []socket.HeaderAssertion{{Name: "Content-Type", Regex: "^application/json"}},
+		},
+		{
+			name:       "fails when the header is missing",
+			assertions: []socket.HeaderAssertion{{Name: "Content-Security-Policy"}},
+			wantErr:    true,
+		},
+		{
+			name:       "fails when the header value differs",
+			assertions: []socket.HeaderAssertion{{Name: "X-Version", Equals: "1.4.3"}},
+			wantErr:    true,
+		},
+		{
+			name:       "fails when the header does not match the regex",
+			assertions: []socket.HeaderAssertion{{Name: "Content-Type", Regex: "^text/html"}},
+			wantErr:    true,
+		},
+		{
+			name:       "fails on an invalid regex",
+			assertions: []socket.HeaderAssertion{{Name: "Content-Type", Regex: "("}},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp := httpExpectations{headers: tt.assertions}
+			if err := exp.checkHeaders(header); (err != nil) != tt.wantErr {
+				t.Fatalf("checkHeaders(): error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHttpExpectations_ReadBody(t *testing.T) {
+	exp := httpExpectations{maxBodyBytes: 4}
+
+	got, err := exp.readBody(strings.NewReader("healthy"))
+	if err != nil {
+		t.Fatalf("readBody(): unexpected error: %v", err)
+	}
+
+	if string(got) != "heal" {
+		t.Fatalf("readBody(): got %q, want %q", got, "heal")
+	}
+}
+
+func TestHttpRunner_RunTest_BodyAssertions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("down for maintenance"))
+	}))
+	defer server.Close()
+
+	sock := newTestServerSocket(t, server)
+	sock.ExpectedBodyNotContains = "maintenance"
+
+	runner := httpRunner{client: &http.Client{}, logger: &MockLogger{}}
+	got := runner.RunTest(context.Background(), sock)
+
+	if got.Passed {
+		t.Fatal("httpRunner.RunTest(): expected the test to fail")
+	}
+
+	if got.Error == nil || !strings.Contains(got.Error.Error(), "maintenance") {
+		t.Fatalf("httpRunner.RunTest(): unexpected error: %v", got.Error)
+	}
+
+	if got.ResponseCode != http.StatusOK {
+		t.Fatalf("httpRunner.RunTest(): response code = %d, want %d", got.ResponseCode, http.StatusOK)
+	}
+}
@@ -0,0 +1,275 @@
+package netrunner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+// Supported values of socket.HTTPAuth.Type.
+const (
+	authBasic  = "basic"
+	authBearer = "bearer"
+	authOAuth2 = "oauth2"
+)
+
+// tokenExpiryMargin is subtracted from the lifetime of OAuth2 tokens so that a token is not sent just before it expires.
+const tokenExpiryMargin = 10 * time.Second
+
+// maxTokenResponseBytes is the maximum number of bytes read from the response of an OAuth2 token endpoint.
+const maxTokenResponseBytes = 64 << 10
+
+// oauth2Tokens caches the OAuth2 tokens obtained during the run.
+var oauth2Tokens = &tokenCache{entries: make(map[string]*tokenEntry)}
+
+// validateHTTPAuth checks that the auth configuration of the given socket is complete. The secrets are only
+// resolved when a request is made.
+func validateHTTPAuth(sock socket.Socket) error {
+	auth := sock.AuthHTTP
+
+	for name := range sock.HeadersHTTP {
+		if strings.EqualFold(name, "Authorization") {
+			return errors.New("auth_http cannot be combined with an Authorization header")
+		}
+	}
+
+	switch auth.Type {
+	case authBasic:
+		if auth.Username == "" {
+			return errors.New("basic auth requires a username")
+		}
+		return validateSecretRef("password", auth.Password)
+	case authBearer:
+		return validateSecretRef("token", auth.Token)
+	case authOAuth2:
+		if auth.TokenURL == "" || auth.ClientID == "" {
+			return errors.New("oauth2 auth requires a token_url and a client_id")
+		}
+		if u, err := url.Parse(auth.TokenURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return errors.New("oauth2 token_url must be a HTTP or HTTPS URL")
+		}
+		return validateSecretRef("client_secret", auth.ClientSecret)
+	default:
+		return fmt.Errorf("unsupported auth type %q, expected %s, %s or %s", auth.Type, authBasic, authBearer, authOAuth2)
+	}
+}
+
+// validateSecretRef checks that exactly one source of the named secret is set.
+func validateSecretRef(name string, ref socket.SecretRef) error {
+	if (ref.Env == "") == (ref.File == "") {
+		return fmt.Errorf("exactly one of env and file must be set for the %s", name)
+	}
+
+	return nil
+}
+
+// secretValue returns the value of the secret referenced by ref, read either from an environment variable or from
+// a file. A non-nil error is returned if the variable is not set or empty, or if the file cannot be read or is empty.
+func secretValue(ref socket.SecretRef) (string, error) {
+	if ref.Env != "" {
+		value := os.Getenv(ref.Env)
+		if value == "" {
+			return "", fmt.Errorf("environment variable %s is not set", ref.Env)
+		}
+		return value, nil
+	}
+
+	data, err := os.ReadFile(ref.File)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+
+	value := strings.TrimRight(string(data), " \t\r\n")
+	if value == "" {
+		return "", fmt.Errorf("secret file %s is empty", ref.File)
+	}
+
+	return value, nil
+}
+
+// authorize sets the Authorization header of the request according to the auth configuration of the socket, if any.
+// For OAuth2, a cached token is used if available. Otherwise, a new token is obtained from the token endpoint. The
+// token sent is returned, or an empty string if the request is not authenticated using OAuth2.
+func (runner *httpRunner) authorize(ctx context.Context, req *http.Request, auth *socket.HTTPAuth) (string, error) {
+	if auth == nil {
+		return "", nil
+	}
+
+	switch auth.Type {
+	case authBasic:
+		password, err := secretValue(auth.Password)
+		if err != nil {
+			return "", fmt.Errorf("failed to load the basic auth password: %w", err)
+		}
+		req.SetBasicAuth(auth.Username, password)
+	case authBearer:
+		token, err := secretValue(auth.Token)
+		if err != nil {
+			return "", fmt.Errorf("failed to load the bearer token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case authOAuth2:
+		token, err := oauth2Tokens.token(ctx, runner.tokenClient, auth)
+		if err != nil {
+			return "", fmt.Errorf("failed to obtain an OAuth2 token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return token, nil
+	}
+
+	return "", nil
+}
+
+// prepare obtains the OAuth2 token for the socket if it uses OAuth2 auth and no valid token is cached, so that
+// the token request does not count toward the duration of the test.
+func (runner *httpRunner) prepare(ctx context.Context, sock socket.Socket) error {
+	if sock.AuthHTTP == nil || sock.AuthHTTP.Type != authOAuth2 {
+		return nil
+	}
+
+	if _, err := oauth2Tokens.token(ctx, runner.tokenClient, sock.AuthHTTP); err != nil {
+		return fmt.Errorf("failed to obtain an OAuth2 token: %w", err)
+	}
+
+	return nil
+}
+
+// tokenCache holds the OAuth2 tokens obtained from token endpoints, keyed by the token endpoint, client and scopes.
+type tokenCache struct {
+	mu      sync.Mutex
+	entries map[string]*tokenEntry
+}
+
+// tokenEntry is a cached OAuth2 token. Its mutex is held while the token is obtained, so that sockets sharing
+// the token wait for a single request to the token endpoint.
+type tokenEntry struct {
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time // zero if the token does not expire
+}
+
+// valid reports whether the entry holds a token which has not expired yet. The entry mutex must be held.
+func (e *tokenEntry) valid() bool {
+	return e.token != "" && (e.expiresAt.IsZero() || time.Now().Before(e.expiresAt))
+}
+
+// entry returns the cache entry for the given auth configuration, creating it if it does not exist.
+func (c *tokenCache) entry(auth *socket.HTTPAuth) *tokenEntry {
+	key := strings.Join([]string{auth.TokenURL, auth.ClientID, strings.Join(auth.Scopes, " ")}, "\n")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		e = &tokenEntry{}
+		c.entries[key] = e
+	}
+
+	return e
+}
+
+// token returns a valid token for the given auth configuration, obtaining a new one using the provided client if
+// none is cached or the cached one has expired.
+func (c *tokenCache) token(ctx context.Context, client *http.Client, auth *socket.HTTPAuth) (string, error) {
+	e := c.entry(auth)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.valid() {
+		return e.token, nil
+	}
+
+	token, expiresIn, err := fetchOAuth2Token(ctx, client, auth)
+	if err != nil {
+		return "", err
+	}
+
+	e.token, e.expiresAt = token, time.Time{}
+	if expiresIn > 0 {
+		e.expiresAt = time.Now().Add(max(expiresIn-tokenExpiryMargin, 0))
+	}
+
+	return token, nil
+}
+
+// invalidate removes the given token from the cache, e.g. after it was rejected, so that the next request obtains
+// a new one. A different token cached in the meantime is kept.
+func (c *tokenCache) invalidate(auth *socket.HTTPAuth, token string) {
+	e := c.entry(auth)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.token == token {
+		e.token, e.expiresAt = "", time.Time{}
+	}
+}
+
+// tokenResponse is the successful response of an OAuth2 token endpoint (RFC 6749, section 5.1).
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// fetchOAuth2Token obtains a token from the token endpoint using the client credentials grant (RFC 6749, section 4.4).
+// The client authenticates using HTTP Basic auth. The token and its lifetime are returned, the lifetime is zero if
+// the token endpoint did not specify it.
+func fetchOAuth2Token(ctx context.Context, client *http.Client, auth *socket.HTTPAuth) (string, time.Duration, error) {
+	secret, err := secretValue(auth.ClientSecret)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to load the client secret: %w", err)
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(auth.Scopes) > 0 {
+		form.Set("scope", strings.Join(auth.Scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, auth.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", fmt.Sprintf("dish/%s", agentVersion))
+	req.SetBasicAuth(url.QueryEscape(auth.ClientID), url.QueryEscape(secret))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+
+	var body tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxTokenResponseBytes)).Decode(&body); err != nil {
+		return "", 0, fmt.Errorf("failed to decode the token response: %w", err)
+	}
+
+	if body.AccessToken == "" {
+		return "", 0, errors.New("token response contains no access_token")
+	}
+
+	if body.TokenType != "" && !strings.EqualFold(body.TokenType, "bearer") {
+		return "", 0, fmt.Errorf("unsupported token type %q", body.TokenType)
+	}
+
+	return body.AccessToken, time.Duration(body.ExpiresIn) * time.Second, nil
+}
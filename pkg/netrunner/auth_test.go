@@ -0,0 +1,312 @@
+package netrunner
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+func TestValidateHTTPAuth(t *testing.T) {
+	env := socket.SecretRef{Env: "DISH_SECRET"}
+
+	tests := []struct {
+		name    string
+		sock    socket.Socket
+		wantErr bool
+	}{
+		{
+			name: "basic auth",
+			sock: socket.Socket{AuthHTTP: &socket.HTTPAuth{Type: "basic", Username: "dish", Password: env}},
+		},
+		{
+			name:    "basic auth without a username",
+			sock:    socket.Socket{AuthHTTP: &socket.HTTPAuth{Type: "basic", Password: env}},
+			wantErr: true,
+		},
+		{
+			name: "bearer token from a file",
+			sock: socket.Socket{AuthHTTP: &socket.HTTPAuth{Type: "bearer", Token: socket.SecretRef{File: "/run/secrets/token"}}},
+		},
+		{
+			name:    "bearer token without a source",
+			sock:    socket.Socket{AuthHTTP: &socket.HTTPAuth{Type: "bearer"}},
+			wantErr: true,
+		},
+		{
+			name:    "bearer token with two sources",
+			sock:    socket.Socket{AuthHTTP: &socket.HTTPAuth{Type: "bearer", Token: socket.SecretRef{Env: "DISH_SECRET", File: "/run/secrets/token"}}},
+			wantErr: true,
+		},
+		{
+			name: "oauth2 client credentials",
+			sock: socket.Socket{AuthHTTP: &socket.HTTPAuth{Type: "oauth2", TokenURL: "https://auth.example.com/token", ClientID: "dish", ClientSecret: env}},
+		},
+		{
+			name:    "oauth2 without a token URL",
+			sock:    socket.Socket{AuthHTTP: &socket.HTTPAuth{Type: "oauth2", ClientID: "dish", ClientSecret: env}},
+			wantErr: true,
+		},
+		{
+			name:    "oauth2 with a token URL which is not HTTP",
+			sock:    socket.Socket{AuthHTTP: &socket.HTTPAuth{Type: "oauth2", TokenURL: "auth.example.com/token", ClientID: "dish", ClientSecret: env}},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported type",
+			sock:    socket.Socket{AuthHTTP: &socket.HTTPAuth{Type: "digest"}},
+			wantErr: true,
+		},
+		{
+			name: "combined with an Authorization header",
+			sock: socket.Socket{
+				HeadersHTTP: map[string]string{"authorization": "Bearer static"},
+				AuthHTTP:    &socket.HTTPAuth{Type: "bearer", Token: env},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateHTTPAuth(tt.sock); (err != nil) != tt.wantErr {
+				t.Errorf("validateHTTPAuth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSecretValue(t *testing.T) {
+	dir := t.TempDir()
+
+	secretFile := filepath.Join(dir, "secret")
+	if err := os.WriteFile(secretFile, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatalf("failed to write secret file: %v", err)
+	}
+
+	emptyFile := filepath.Join(dir, "empty")
+	if err := os.WriteFile(emptyFile, []byte("\n"), 0o600); err != nil {
+		t.Fatalf("failed to write secret file: %v", err)
+	}
+
+	t.Setenv("DISH_TEST_SECRET", "from-env")
+	t.Setenv("DISH_TEST_EMPTY_SECRET", "")
+
+	tests := []struct {
+		name    string
+		ref     socket.SecretRef
+		want    string
+		wantErr bool
+	}{
+		{name: "environment variable", ref: socket.SecretRef{Env: "DISH_TEST_SECRET"}, want: "from-env"},
+		{name: "empty environment variable", ref: socket.SecretRef{Env: "DISH_TEST_EMPTY_SECRET"}, wantErr: true},
+		{name: "file with a trailing newline", ref: socket.SecretRef{File: secretFile}, want: "s3cret"},
+		{name: "empty file", ref: socket.SecretRef{File: emptyFile}, wantErr: true},
+		{name: "missing file", ref: socket.SecretRef{File: filepath.Join(dir, "missing")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := secretValue(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("secretValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("secretValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHttpRunner_RunTest_Auth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if (ok && user == "dish" && password == "s3cret") || r.Header.Get("Authorization") == "Bearer t0ken" {
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	t.Setenv("DISH_TEST_PASSWORD", "s3cret")
+	t.Setenv("DISH_TEST_TOKEN", "t0ken")
+	t.Setenv("DISH_TEST_WRONG_TOKEN", "wrong")
+
+	tests := []struct {
+		name     string
+		auth     *socket.HTTPAuth
+		wantPass bool
+	}{
+		{
+			name:     "basic auth",
+			auth:     &socket.HTTPAuth{Type: "basic", Username: "dish", Password: socket.SecretRef{Env: "DISH_TEST_PASSWORD"}},
+			wantPass: true,
+		},
+		{
+			name:     "bearer token",
+			auth:     &socket.HTTPAuth{Type: "bearer", Token: socket.SecretRef{Env: "DISH_TEST_TOKEN"}},
+			wantPass: true,
+		},
+		{
+			name: "wrong bearer token",
+			auth: &socket.HTTPAuth{Type: "bearer", Token: socket.SecretRef{Env: "DISH_TEST_WRONG_TOKEN"}},
+		},
+		{
+			name: "missing secret",
+			auth: &socket.HTTPAuth{Type: "bearer", Token: socket.SecretRef{Env: "DISH_TEST_MISSING_TOKEN"}},
+		},
+		{
+			name: "no auth",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sock := newTestServerSocket(t, server)
+			sock.AuthHTTP = tt.auth
+
+			runner, err := newHTTPRunner(sock, &MockLogger{})
+			if err != nil {
+				t.Fatalf("newHTTPRunner(): unexpected error: %v", err)
+			}
+
+			if got := runner.RunTest(context.Background(), sock); got.Passed != tt.wantPass {
+				t.Errorf("httpRunner.RunTest(): expected passed = %v, got %v (error: %v)", tt.wantPass, got.Passed, got.Error)
+			}
+		})
+	}
+}
+
+func TestHttpRunner_RunTest_OAuth2(t *testing.T) {
+	var tokenRequests atomic.Int32
+
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientID, secret, _ := r.BasicAuth()
+		if r.Method != http.MethodPost || clientID != "dish" || secret != "s3cret" ||
+			r.FormValue("grant_type") != "client_credentials" || r.FormValue("scope") != "health:read" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		n := tokenRequests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"token-` + strconv.Itoa(int(n)) + `","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+
+	// The server only accepts the second token, so the first one obtained is rejected.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("DISH_TEST_CLIENT_SECRET", "s3cret")
+
+	sock := newTestServerSocket(t, server)
+	sock.AuthHTTP = &socket.HTTPAuth{
+		Type:         "oauth2",
+		TokenURL:     tokenServer.URL,
+		ClientID:     "dish",
+		ClientSecret: socket.SecretRef{Env: "DISH_TEST_CLIENT_SECRET"},
+		Scopes:       []string{"health:read"},
+	}
+
+	runner, err := newHTTPRunner(sock, &MockLogger{})
+	if err != nil {
+		t.Fatalf("newHTTPRunner(): unexpected error: %v", err)
+	}
+
+	if got := runner.RunTest(context.Background(), sock); got.Passed {
+		t.Fatalf("httpRunner.RunTest(): expected the check with the rejected token to fail")
+	}
+
+	// The rejected token is dropped, so a new one is obtained and then reused by the following checks.
+	for range 3 {
+		if got := runner.RunTest(context.Background(), sock); !got.Passed {
+			t.Fatalf("httpRunner.RunTest(): expected the check to pass, got error: %v", got.Error)
+		}
+	}
+
+	if got := tokenRequests.Load(); got != 2 {
+		t.Errorf("expected 2 token requests, got %d", got)
+	}
+}
+
+func TestHttpRunner_RunTest_OAuth2Error(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer tokenServer.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	t.Setenv("DISH_TEST_CLIENT_SECRET", "wrong")
+
+	sock := newTestServerSocket(t, server)
+	sock.AuthHTTP = &socket.HTTPAuth{
+		Type:         "oauth2",
+		TokenURL:     tokenServer.URL,
+		ClientID:     "dish",
+		ClientSecret: socket.SecretRef{Env: "DISH_TEST_CLIENT_SECRET"},
+	}
+
+	runner, err := newHTTPRunner(sock, &MockLogger{})
+	if err != nil {
+		t.Fatalf("newHTTPRunner(): unexpected error: %v", err)
+	}
+
+	if got := runner.RunTest(context.Background(), sock); got.Passed || got.Error == nil {
+		t.Errorf("httpRunner.RunTest(): expected the check to fail when no token can be obtained, got passed = %v", got.Passed)
+	}
+}
+
+func TestRunTest_OAuth2TokenNotTimed(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"access_token":"t0ken","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("DISH_TEST_CLIENT_SECRET", "s3cret")
+
+	sock := newTestServerSocket(t, server)
+	sock.MaxResponseTimeMs = 200
+	sock.AuthHTTP = &socket.HTTPAuth{
+		Type:         "oauth2",
+		TokenURL:     tokenServer.URL,
+		ClientID:     "dish-not-timed",
+		ClientSecret: socket.SecretRef{Env: "DISH_TEST_CLIENT_SECRET"},
+	}
+
+	runner, err := newHTTPRunner(sock, &MockLogger{})
+	if err != nil {
+		t.Fatalf("newHTTPRunner(): unexpected error: %v", err)
+	}
+
+	// The slow token request is made before the check is timed, so it does not exceed the maximum response time.
+	got := runTest(context.Background(), runner, sock)
+	if !got.Passed {
+		t.Fatalf("runTest(): expected the check to pass, got error: %v", got.Error)
+	}
+
+	if got.Duration >= 200*time.Millisecond || got.HTTPTimings.TimeToFirstByte >= 200*time.Millisecond {
+		t.Errorf("runTest(): expected the token request not to be timed, got duration %v and time to first byte %v", got.Duration, got.HTTPTimings.TimeToFirstByte)
+	}
+}
@@ -0,0 +1,122 @@
+package netrunner
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+// lookupIPAddr resolves a host to its IP addresses. It is a variable so that tests can replace it.
+var lookupIPAddr = net.DefaultResolver.LookupIPAddr
+
+// targetIPKey is the context key under which the IP address a test is run against is stored.
+type targetIPKey struct{}
+
+// withTargetIP returns a copy of the context which makes the runners connect to the given IP address instead
+// of the address the socket host resolves to.
+func withTargetIP(ctx context.Context, ip net.IP) context.Context {
+	return context.WithValue(ctx, targetIPKey{}, ip)
+}
+
+// targetIP returns the IP address set on the context by withTargetIP, or nil if none is set.
+func targetIP(ctx context.Context) net.IP {
+	ip, _ := ctx.Value(targetIPKey{}).(net.IP)
+	return ip
+}
+
+// dialAddress returns the address to dial instead of the given host:port address. If a target IP address
+// is set on the context, it replaces the host. Otherwise, the address is returned unchanged.
+func dialAddress(ctx context.Context, addr string) string {
+	ip := targetIP(ctx)
+	if ip == nil {
+		return addr
+	}
+
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+
+	return net.JoinHostPort(ip.String(), port)
+}
+
+// dialContext connects to the address on the named network like net.Dialer.DialContext, connecting to the
+// target IP address set on the context instead of the host of the address, if any.
+func dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	d := net.Dialer{}
+	return d.DialContext(ctx, network, dialAddress(ctx, addr))
+}
+
+// dialSocket connects to the address on the named network like dialContext if it is the address of the socket,
+// applying its ConnectTo override. Any other address, e.g. of a redirect to another host, is connected to
+// unchanged, ignoring the target IP address set on the context.
+func dialSocket(ctx context.Context, sock socket.Socket, network, addr string) (net.Conn, error) {
+	if !isSocketAddress(sock, addr) {
+		d := net.Dialer{}
+		return d.DialContext(ctx, network, addr)
+	}
+
+	return dialContext(ctx, network, connectAddress(sock, addr))
+}
+
+// connectHost returns the host the socket connects to. It is the host of the ConnectTo address if set,
+// otherwise the hostname of the socket host.
+func connectHost(sock socket.Socket) string {
+	if sock.ConnectTo == "" {
+		return hostname(sock.Host)
+	}
+
+	host, _ := splitConnectTo(sock.ConnectTo)
+	return host
+}
+
+// isSocketAddress reports whether the given host:port address is the address of the socket itself, i.e. the
+// hostname of the socket host and the socket port. Other addresses, e.g. of a redirect to another host, are not.
+func isSocketAddress(sock socket.Socket, addr string) bool {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(host, hostname(sock.Host)) && port == strconv.Itoa(sock.Port)
+}
+
+// connectAddress returns the address to connect to instead of the given host:port address. If ConnectTo is set
+// on the socket and the address is the address of the socket, the host of ConnectTo replaces the host of the
+// address, and so does its port, if any. Like curl's --connect-to, other addresses are returned unchanged.
+func connectAddress(sock socket.Socket, addr string) string {
+	if sock.ConnectTo == "" || !isSocketAddress(sock, addr) {
+		return addr
+	}
+
+	host, port := splitConnectTo(sock.ConnectTo)
+	if port == "" {
+		_, port, _ = net.SplitHostPort(addr)
+	}
+
+	return net.JoinHostPort(host, port)
+}
+
+// splitConnectTo splits a ConnectTo address into its host and port. The port is empty if the address does not
+// include one. IPv6 addresses without a port can be written with or without brackets.
+func splitConnectTo(connectTo string) (host, port string) {
+	host, port, err := net.SplitHostPort(connectTo)
+	if err != nil {
+		return strings.Trim(connectTo, "[]"), ""
+	}
+
+	return host, port
+}
+
+// resolveIPAddrs returns the IP addresses of the given host. If a target IP address is set on the context,
+// only that address is returned.
+func resolveIPAddrs(ctx context.Context, host string) ([]net.IPAddr, error) {
+	if ip := targetIP(ctx); ip != nil {
+		return []net.IPAddr{{IP: ip}}, nil
+	}
+
+	return lookupIPAddr(ctx, host)
+}
@@ -0,0 +1,402 @@
+package netrunner
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+// stubLookupIPAddr makes lookupIPAddr resolve every host to the given addresses for the duration of the test.
+func stubLookupIPAddr(t *testing.T, ips ...string) {
+	t.Helper()
+
+	addrs := make([]net.IPAddr, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.IPAddr{IP: net.ParseIP(ip)}
+	}
+
+	original := lookupIPAddr
+	lookupIPAddr = func(context.Context, string) ([]net.IPAddr, error) {
+		return addrs, nil
+	}
+
+	t.Cleanup(func() {
+		lookupIPAddr = original
+	})
+}
+
+func TestDialAddress(t *testing.T) {
+	tests := []struct {
+		name string
+		ip   net.IP
+		addr string
+		want string
+	}{
+		{
+			name: "returns the address unchanged without a target IP",
+			addr: "example.com:443",
+			want: "example.com:443",
+		},
+		{
+			name: "replaces the host with the target IPv4 address",
+			ip:   net.ParseIP("192.0.2.10"),
+			addr: "example.com:443",
+			want: "192.0.2.10:443",
+		},
+		{
+			name: "replaces the host with the target IPv6 address",
+			ip:   net.ParseIP("2001:db8::10"),
+			addr: "example.com:80",
+			want: "[2001:db8::10]:80",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.ip != nil {
+				ctx = withTargetIP(ctx, tt.ip)
+			}
+
+			if got := dialAddress(ctx, tt.addr); got != tt.want {
+				t.Errorf("dialAddress() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunTest_AllAddresses_TCP(t *testing.T) {
+	// The listener only accepts connections on 127.0.0.1, so connections to 127.0.0.2 are refused.
+	port := newTestLineServer(t, "")
+	stubLookupIPAddr(t, "127.0.0.1", "127.0.0.2")
+
+	sock := socket.Socket{ID: "round_robin", Host: "backend.test", Port: port, AllAddresses: true}
+
+	got := runTest(context.Background(), &tcpRunner{logger: &MockLogger{}}, sock)
+
+	if got.Passed || got.Error == nil {
+		t.Fatalf("runTest(): expected a failure when one of the addresses is down, got passed = %v", got.Passed)
+	}
+
+	if len(got.AddressResults) != 2 {
+		t.Fatalf("runTest(): expected 2 address results, got %d", len(got.AddressResults))
+	}
+
+	for i, want := range []struct {
+		address string
+		passed  bool
+	}{
+		{address: "127.0.0.1", passed: true},
+		{address: "127.0.0.2", passed: false},
+	} {
+		if sub := got.AddressResults[i]; sub.Address != want.address || sub.Passed != want.passed {
+			t.Errorf("runTest(): address result %d = %s (passed: %v), want %s (passed: %v)", i, sub.Address, sub.Passed, want.address, want.passed)
+		}
+	}
+}
+
+func TestRunTest_AllAddresses_HTTP(t *testing.T) {
+	var hosts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.Host)
+	}))
+	defer server.Close()
+
+	stubLookupIPAddr(t, "127.0.0.1")
+
+	sock := newTestServerSocket(t, server)
+	sock.Host = "http://backend.test"
+	sock.AllAddresses = true
+
+	runner, err := newHTTPRunner(sock, &MockLogger{})
+	if err != nil {
+		t.Fatalf("newHTTPRunner(): unexpected error: %v", err)
+	}
+
+	got := runTest(context.Background(), runner, sock)
+	if !got.Passed {
+		t.Fatalf("runTest(): expected the check to pass, got error: %v", got.Error)
+	}
+
+	if len(got.AddressResults) != 1 || got.AddressResults[0].Address != "127.0.0.1" {
+		t.Errorf("runTest(): unexpected address results: %+v", got.AddressResults)
+	}
+
+	wantHost := net.JoinHostPort("backend.test", strconv.Itoa(sock.Port))
+	if len(hosts) != 1 || hosts[0] != wantHost {
+		t.Errorf("runTest(): expected the request to keep the host %s, got %v", wantHost, hosts)
+	}
+}
+
+func TestNewDNSRunner_AllAddresses(t *testing.T) {
+	if _, err := newDNSRunner(socket.Socket{Host: "example.com", AllAddresses: true}, &MockLogger{}); err == nil {
+		t.Error("newDNSRunner(): expected an error when all addresses are to be checked")
+	}
+}
+
+func TestConnectAddress(t *testing.T) {
+	tests := []struct {
+		name      string
+		connectTo string
+		addr      string
+		want      string
+	}{
+		{
+			name: "returns the address unchanged without an override",
+			addr: "example.com:443",
+			want: "example.com:443",
+		},
+		{
+			name:      "replaces the host and keeps the port",
+			connectTo: "192.0.2.10",
+			addr:      "example.com:443",
+			want:      "192.0.2.10:443",
+		},
+		{
+			name:      "matches the socket host case-insensitively",
+			connectTo: "192.0.2.10",
+			addr:      "Example.com:443",
+			want:      "192.0.2.10:443",
+		},
+		{
+			name:      "replaces the host and the port",
+			connectTo: "origin.example.com:8443",
+			addr:      "example.com:443",
+			want:      "origin.example.com:8443",
+		},
+		{
+			name:      "replaces the host with an IPv6 address without brackets",
+			connectTo: "2001:db8::10",
+			addr:      "example.com:443",
+			want:      "[2001:db8::10]:443",
+		},
+		{
+			name:      "replaces the host with an IPv6 address in brackets",
+			connectTo: "[2001:db8::10]",
+			addr:      "example.com:443",
+			want:      "[2001:db8::10]:443",
+		},
+		{
+			name:      "replaces the host and the port with an IPv6 address",
+			connectTo: "[2001:db8::10]:8080",
+			addr:      "example.com:443",
+			want:      "[2001:db8::10]:8080",
+		},
+		{
+			name:      "returns the address of another host unchanged",
+			connectTo: "192.0.2.10",
+			addr:      "www.example.com:443",
+			want:      "www.example.com:443",
+		},
+		{
+			name:      "returns the address with another port unchanged",
+			connectTo: "192.0.2.10",
+			addr:      "example.com:80",
+			want:      "example.com:80",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sock := socket.Socket{Host: "https://example.com", Port: 443, ConnectTo: tt.connectTo}
+
+			if got := connectAddress(sock, tt.addr); got != tt.want {
+				t.Errorf("connectAddress() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTcpRunner_RunTest_ConnectTo(t *testing.T) {
+	port := newTestLineServer(t, "")
+
+	sock := socket.Socket{ID: "origin", Host: "backend.test", Port: 1, ConnectTo: net.JoinHostPort("127.0.0.1", strconv.Itoa(port))}
+
+	got := (&tcpRunner{logger: &MockLogger{}}).RunTest(context.Background(), sock)
+	if !got.Passed {
+		t.Errorf("tcpRunner.RunTest(): expected the check to pass, got error: %v", got.Error)
+	}
+}
+
+func TestHttpRunner_RunTest_ConnectTo(t *testing.T) {
+	cert := newTestCertificate(t, time.Now().Add(24*time.Hour))
+	caFile, _ := writeTestCertificate(t, cert)
+
+	var gotHost string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHost = r.Host
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	server.StartTLS()
+	defer server.Close()
+
+	// The certificate is only valid for localhost, so the check only passes if the socket host is used for SNI
+	// and certificate verification while the connection is made to the server address.
+	sock := socket.Socket{
+		ID:                "origin",
+		Host:              "https://localhost",
+		Port:              443,
+		PathHTTP:          "/",
+		ExpectedHTTPCodes: []int{200},
+		TLSCAFile:         caFile,
+		ConnectTo:         server.Listener.Addr().String(),
+	}
+
+	runner, err := newHTTPRunner(sock, &MockLogger{})
+	if err != nil {
+		t.Fatalf("newHTTPRunner(): unexpected error: %v", err)
+	}
+
+	got := runner.RunTest(context.Background(), sock)
+	if !got.Passed {
+		t.Fatalf("httpRunner.RunTest(): expected the check to pass, got error: %v", got.Error)
+	}
+
+	if gotHost != "localhost:443" {
+		t.Errorf("httpRunner.RunTest(): expected the Host header localhost:443, got %s", gotHost)
+	}
+}
+
+// newTestRedirectServers starts a server which responds with "final" and an origin server which redirects
+// every request to it using the given host. The origin server address is returned.
+func newTestRedirectServers(t *testing.T, finalListener net.Listener, finalHost string) string {
+	t.Helper()
+
+	final := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("final"))
+	}))
+	final.Listener = finalListener
+	final.Start()
+	t.Cleanup(final.Close)
+
+	_, port, _ := net.SplitHostPort(finalListener.Addr().String())
+	location := "http://" + net.JoinHostPort(finalHost, port) + "/final"
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/final" {
+			_, _ = w.Write([]byte("origin"))
+			return
+		}
+		http.Redirect(w, r, location, http.StatusFound)
+	}))
+	t.Cleanup(origin.Close)
+
+	return origin.Listener.Addr().String()
+}
+
+func TestHttpRunner_RunTest_ConnectToRedirect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start a TCP listener: %v", err)
+	}
+
+	origin := newTestRedirectServers(t, ln, "localhost")
+
+	// Only the socket host and port are connected to the origin, the redirect target is connected to as usual.
+	sock := socket.Socket{
+		ID:                   "origin",
+		Host:                 "http://backend.test",
+		Port:                 80,
+		PathHTTP:             "/",
+		ExpectedHTTPCodes:    []int{200},
+		ExpectedBodyContains: "final",
+		ConnectTo:            origin,
+	}
+
+	runner, err := newHTTPRunner(sock, &MockLogger{})
+	if err != nil {
+		t.Fatalf("newHTTPRunner(): unexpected error: %v", err)
+	}
+
+	if got := runner.RunTest(context.Background(), sock); !got.Passed {
+		t.Errorf("httpRunner.RunTest(): expected the redirect target to be fetched, got error: %v", got.Error)
+	}
+}
+
+func TestRunTest_AllAddresses_Redirect(t *testing.T) {
+	// The redirect target listens on the IPv6 loopback only, so it is not reached if the connection is made
+	// to the target IP address of the socket.
+	ln, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback not available: %v", err)
+	}
+
+	origin := newTestRedirectServers(t, ln, "::1")
+	_, port, _ := net.SplitHostPort(origin)
+
+	stubLookupIPAddr(t, "127.0.0.1")
+
+	sock := socket.Socket{
+		ID:                   "origin",
+		Host:                 "http://backend.test",
+		PathHTTP:             "/",
+		ExpectedHTTPCodes:    []int{200},
+		ExpectedBodyContains: "final",
+		AllAddresses:         true,
+	}
+	sock.Port, _ = strconv.Atoi(port)
+
+	runner, err := newHTTPRunner(sock, &MockLogger{})
+	if err != nil {
+		t.Fatalf("newHTTPRunner(): unexpected error: %v", err)
+	}
+
+	if got := runTest(context.Background(), runner, sock); !got.Passed {
+		t.Errorf("runTest(): expected the redirect target to be fetched, got error: %v", got.Error)
+	}
+}
+
+func TestHttpRunner_RunTest_EnvironmentProxy(t *testing.T) {
+	proxy, requests := newTestHTTPProxy(t, "", "", "")
+	t.Setenv("HTTP_PROXY", proxy)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("backend"))
+	}))
+	defer server.Close()
+
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	stubLookupIPAddr(t, "127.0.0.1")
+
+	tests := []struct {
+		name   string
+		modify func(sock *socket.Socket)
+	}{
+		{name: "connect_to", modify: func(sock *socket.Socket) { sock.ConnectTo = server.Listener.Addr().String() }},
+		{name: "all_addresses", modify: func(sock *socket.Socket) { sock.AllAddresses = true }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sock := socket.Socket{ID: "backend", Host: "http://backend.test", PathHTTP: "/", ExpectedHTTPCodes: []int{200}, ExpectedBodyContains: "backend"}
+			sock.Port, _ = strconv.Atoi(port)
+			tt.modify(&sock)
+
+			runner, err := newHTTPRunner(sock, &MockLogger{})
+			if err != nil {
+				t.Fatalf("newHTTPRunner(): unexpected error: %v", err)
+			}
+
+			// The proxy settings of the environment are read only once per process, so the transport is checked
+			// as well in case they were read before HTTP_PROXY was set.
+			if transport := runner.(*httpRunner).client.Transport.(*http.Transport); transport.Proxy != nil {
+				t.Errorf("newHTTPRunner(): expected the client not to use a proxy")
+			}
+
+			if got := runTest(context.Background(), runner, sock); !got.Passed {
+				t.Errorf("runTest(): expected the check to connect to the socket address directly, got error: %v", got.Error)
+			}
+
+			if targets := requests.requested(); len(targets) != 0 {
+				t.Errorf("expected the proxy from the environment not to be used, got requests for %v", targets)
+			}
+		})
+	}
+}
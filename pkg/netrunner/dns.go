@@ -0,0 +1,231 @@
+package netrunner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.vxn.dev/dish/pkg/logger"
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+// Supported values of socket.DNSMatch.
+const (
+	dnsMatchExact    = "exact"
+	dnsMatchContains = "contains"
+)
+
+// dnsRecordTypes lists the supported values of socket.DNSRecordType.
+var dnsRecordTypes = []string{"A", "AAAA", "CNAME", "MX", "TXT", "NS", "SRV"}
+
+type dnsRunner struct {
+	logger logger.Logger
+}
+
+// newDNSRunner returns a new dnsRunner. A non-nil error is returned if the socket host is empty or the
+// record type or match mode is not supported.
+func newDNSRunner(sock socket.Socket, logger logger.Logger) (NetRunner, error) {
+	if sock.Host == "" {
+		return nil, fmt.Errorf("protocol %s requires a host for the socket %s", ProtocolDNS, sock.ID)
+	}
+
+	if !slices.Contains(dnsRecordTypes, dnsRecordType(sock)) {
+		return nil, fmt.Errorf("unsupported DNS record type %q for the socket %s", sock.DNSRecordType, sock.ID)
+	}
+
+	if sock.DNSMatch != "" && sock.DNSMatch != dnsMatchExact && sock.DNSMatch != dnsMatchContains {
+		return nil, fmt.Errorf("unsupported DNS match mode %q for the socket %s", sock.DNSMatch, sock.ID)
+	}
+
+	if sock.AllAddresses {
+		return nil, fmt.Errorf("protocol %s does not support checking all addresses for the socket %s", ProtocolDNS, sock.ID)
+	}
+
+	return &dnsRunner{logger: logger}, nil
+}
+
+// RunTest is used to test DNS records. It resolves the socket host for the configured record type, using
+// the configured nameserver if set. The test passes if the answers match the expected values, or if any
+// answer is returned when no values are expected.
+func (runner *dnsRunner) RunTest(ctx context.Context, sock socket.Socket) socket.Result {
+	name := hostname(sock.Host)
+	recordType := dnsRecordType(sock)
+
+	runner.logger.Debugf("DNS runner: resolve %s %s", recordType, name)
+
+	answers, err := lookupDNS(ctx, newResolver(sock.DNSServer), recordType, name)
+	if err != nil {
+		return socket.Result{Socket: sock, Error: fmt.Errorf("DNS lookup failed: %w", err)}
+	}
+
+	if err := matchDNSAnswers(recordType, answers, sock.DNSExpected, sock.DNSMatch); err != nil {
+		return socket.Result{Socket: sock, Error: err}
+	}
+
+	return socket.Result{Socket: sock, Passed: true}
+}
+
+// dnsRecordType returns the upper-cased record type of the given socket, defaulting to A.
+func dnsRecordType(sock socket.Socket) string {
+	if sock.DNSRecordType == "" {
+		return "A"
+	}
+
+	return strings.ToUpper(sock.DNSRecordType)
+}
+
+// newResolver returns a resolver querying the provided nameserver. If the nameserver does not include
+// a port, port 53 is used. If the nameserver is empty, the system resolver is returned. The Go resolver
+// still consults the hosts file, so A and AAAA records of names listed there are not queried.
+func newResolver(server string) *net.Resolver {
+	if server == "" {
+		return net.DefaultResolver
+	}
+
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			d := net.Dialer{}
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// lookupDNS resolves the records of the given type for the provided name and returns them as normalized strings.
+func lookupDNS(ctx context.Context, resolver *net.Resolver, recordType, name string) ([]string, error) {
+	var answers []string
+
+	switch recordType {
+	case "A", "AAAA":
+		network := "ip4"
+		if recordType == "AAAA" {
+			network = "ip6"
+		}
+
+		ips, err := resolver.LookupIP(ctx, network, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			answers = append(answers, ip.String())
+		}
+
+	case "CNAME":
+		cname, err := resolver.LookupCNAME(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		// The queried name itself is returned if it resolves but has no CNAME record.
+		if normalizeDNSValue(recordType, cname) != normalizeDNSValue(recordType, name) {
+			answers = append(answers, cname)
+		}
+
+	case "MX":
+		records, err := resolver.LookupMX(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, mx := range records {
+			answers = append(answers, mx.Host)
+		}
+
+	case "TXT":
+		records, err := resolver.LookupTXT(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		answers = append(answers, records...)
+
+	case "NS":
+		records, err := resolver.LookupNS(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range records {
+			answers = append(answers, ns.Host)
+		}
+
+	case "SRV":
+		_, records, err := resolver.LookupSRV(ctx, "", "", name)
+		if err != nil {
+			return nil, err
+		}
+		for _, srv := range records {
+			answers = append(answers, net.JoinHostPort(srv.Target, strconv.Itoa(int(srv.Port))))
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported DNS record type: %s", recordType)
+	}
+
+	for i, answer := range answers {
+		answers[i] = normalizeDNSValue(recordType, answer)
+	}
+
+	return answers, nil
+}
+
+// normalizeDNSValue normalizes a DNS answer or an expected value of the given record type so that they can
+// be compared. IP addresses are converted to their canonical form, domain names are lower-cased and stripped
+// of the trailing dot. TXT values are kept as is.
+func normalizeDNSValue(recordType, value string) string {
+	switch recordType {
+	case "A", "AAAA":
+		if ip := net.ParseIP(value); ip != nil {
+			return ip.String()
+		}
+		return value
+	case "TXT":
+		return value
+	case "SRV":
+		host, port, err := net.SplitHostPort(value)
+		if err != nil {
+			return value
+		}
+		return net.JoinHostPort(strings.TrimSuffix(strings.ToLower(host), "."), port)
+	default:
+		return strings.TrimSuffix(strings.ToLower(value), ".")
+	}
+}
+
+// matchDNSAnswers compares the DNS answers with the expected values using the provided match mode.
+// A non-nil error describing the mismatch is returned if the answers do not match.
+func matchDNSAnswers(recordType string, answers, expected []string, mode string) error {
+	if len(answers) == 0 {
+		return fmt.Errorf("no %s records found", recordType)
+	}
+
+	if len(expected) == 0 {
+		return nil
+	}
+
+	want := make([]string, 0, len(expected))
+	for _, value := range expected {
+		want = append(want, normalizeDNSValue(recordType, value))
+	}
+
+	for _, value := range want {
+		if !slices.Contains(answers, value) {
+			return fmt.Errorf("expected %s record %q not found in answers %v", recordType, value, answers)
+		}
+	}
+
+	if mode == dnsMatchContains {
+		return nil
+	}
+
+	for _, answer := range answers {
+		if !slices.Contains(want, answer) {
+			return fmt.Errorf("unexpected %s record %q found in answers %v", recordType, answer, answers)
+		}
+	}
+
+	return nil
+}
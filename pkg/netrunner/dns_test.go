@@ -0,0 +1,235 @@
+package netrunner
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+// newTestDNSServer starts a minimal UDP DNS server on a random local port which answers every A query
+// with the provided IPv4 addresses and every other query with no records. It returns the server address.
+func newTestDNSServer(t *testing.T, ips ...net.IP) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start a DNS server: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			// The question section starts after the 12 byte header and ends with the QTYPE and QCLASS fields.
+			query := buf[:n]
+			end := 12
+			for end < n && query[end] != 0 {
+				end += int(query[end]) + 1
+			}
+			end += 5
+			if end > n {
+				continue
+			}
+			qtype := binary.BigEndian.Uint16(query[end-4 : end-2])
+
+			resp := append([]byte{}, query[:end]...)
+			resp[2] = 0x81 // QR, RD
+			resp[3] = 0x80 // RA
+
+			var answers int
+			if qtype == 1 {
+				for _, ip := range ips {
+					// Name pointer to the question, type A, class IN, TTL 60, 4 bytes of data.
+					resp = append(resp, 0xc0, 0x0c, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+					resp = append(resp, ip.To4()...)
+					answers++
+				}
+			}
+			binary.BigEndian.PutUint16(resp[6:8], uint16(answers))
+			binary.BigEndian.PutUint16(resp[8:10], 0)
+			binary.BigEndian.PutUint16(resp[10:12], 0)
+
+			_, _ = conn.WriteTo(resp, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestDnsRunner_RunTest(t *testing.T) {
+	server := newTestDNSServer(t, net.IPv4(192, 0, 2, 1), net.IPv4(192, 0, 2, 2))
+
+	tests := []struct {
+		name       string
+		sock       socket.Socket
+		wantPassed bool
+	}{
+		{
+			name:       "passes when any answer is returned",
+			sock:       socket.Socket{Host: "example.test", DNSServer: server},
+			wantPassed: true,
+		},
+		{
+			name:       "passes on an exact match",
+			sock:       socket.Socket{Host: "example.test", DNSServer: server, DNSExpected: []string{"192.0.2.2", "192.0.2.1"}},
+			wantPassed: true,
+		},
+		{
+			name:       "passes when the answers contain the expected value",
+			sock:       socket.Socket{Host: "example.test", DNSServer: server, DNSExpected: []string{"192.0.2.1"}, DNSMatch: "contains"},
+			wantPassed: true,
+		},
+		{
+			name: "fails on an exact match with missing expected values",
+			sock: socket.Socket{Host: "example.test", DNSServer: server, DNSExpected: []string{"192.0.2.1"}},
+		},
+		{
+			name: "fails when the answers do not contain the expected value",
+			sock: socket.Socket{Host: "example.test", DNSServer: server, DNSExpected: []string{"192.0.2.3"}, DNSMatch: "contains"},
+		},
+		{
+			name: "fails when no records are found",
+			sock: socket.Socket{Host: "example.test", DNSServer: server, DNSRecordType: "TXT"},
+		},
+		{
+			name: "fails when a resolving name has no CNAME record",
+			sock: socket.Socket{Host: "example.test", DNSServer: server, DNSRecordType: "CNAME"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := dnsRunner{logger: &MockLogger{}}
+
+			got := runner.RunTest(context.Background(), tt.sock)
+			if got.Passed != tt.wantPassed {
+				t.Fatalf("dnsRunner.RunTest(): passed = %v, want %v (error: %v)", got.Passed, tt.wantPassed, got.Error)
+			}
+		})
+	}
+}
+
+func TestNewDNSRunner(t *testing.T) {
+	tests := []struct {
+		name    string
+		sock    socket.Socket
+		wantErr bool
+	}{
+		{
+			name: "returns a dnsRunner with the default record type",
+			sock: socket.Socket{Host: "example.com", Protocol: "dns"},
+		},
+		{
+			name: "returns a dnsRunner for a lower-cased record type",
+			sock: socket.Socket{Host: "example.com", Protocol: "dns", DNSRecordType: "mx", DNSMatch: "contains"},
+		},
+		{
+			name:    "returns an error on an empty host",
+			sock:    socket.Socket{Protocol: "dns"},
+			wantErr: true,
+		},
+		{
+			name:    "returns an error on an unsupported record type",
+			sock:    socket.Socket{Host: "example.com", Protocol: "dns", DNSRecordType: "PTR"},
+			wantErr: true,
+		},
+		{
+			name:    "returns an error on an unsupported match mode",
+			sock:    socket.Socket{Host: "example.com", Protocol: "dns", DNSMatch: "prefix"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewNetRunner(tt.sock, &MockLogger{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewNetRunner(): error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if _, ok := got.(*dnsRunner); !tt.wantErr && !ok {
+				t.Fatalf("NewNetRunner(): expected a dnsRunner, got %T", got)
+			}
+		})
+	}
+}
+
+func TestMatchDNSAnswers(t *testing.T) {
+	tests := []struct {
+		name       string
+		recordType string
+		answers    []string
+		expected   []string
+		mode       string
+		wantErr    bool
+	}{
+		{
+			name:       "matches IPv6 addresses in a different notation",
+			recordType: "AAAA",
+			answers:    []string{"2001:db8::1"},
+			expected:   []string{"2001:0DB8:0:0:0:0:0:1"},
+		},
+		{
+			name:       "matches domain names regardless of case and the trailing dot",
+			recordType: "MX",
+			answers:    []string{"mx1.example.com", "mx2.example.com"},
+			expected:   []string{"MX2.example.com.", "mx1.example.com"},
+		},
+		{
+			name:       "matches SRV records as target and port",
+			recordType: "SRV",
+			answers:    []string{"sip.example.com:5060"},
+			expected:   []string{"sip.example.com.:5060"},
+		},
+		{
+			name:       "fails on TXT values with a different case",
+			recordType: "TXT",
+			answers:    []string{"v=spf1 -all"},
+			expected:   []string{"V=SPF1 -ALL"},
+			wantErr:    true,
+		},
+		{
+			name:       "fails on an exact match with an unexpected answer",
+			recordType: "NS",
+			answers:    []string{"ns1.example.com", "ns3.example.com"},
+			expected:   []string{"ns1.example.com"},
+			wantErr:    true,
+		},
+		{
+			name:       "passes on a contains match with an unexpected answer",
+			recordType: "NS",
+			answers:    []string{"ns1.example.com", "ns3.example.com"},
+			expected:   []string{"ns1.example.com"},
+			mode:       dnsMatchContains,
+		},
+		{
+			name:       "fails on no answers",
+			recordType: "A",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answers := make([]string, 0, len(tt.answers))
+			for _, answer := range tt.answers {
+				answers = append(answers, normalizeDNSValue(tt.recordType, answer))
+			}
+
+			if err := matchDNSAnswers(tt.recordType, answers, tt.expected, tt.mode); (err != nil) != tt.wantErr {
+				t.Fatalf("matchDNSAnswers(): error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
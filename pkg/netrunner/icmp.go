@@ -0,0 +1,136 @@
+package netrunner
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+// Address families which can be selected for ICMP checks.
+const (
+	icmpFamilyIPv4 = "ipv4"
+	icmpFamilyIPv6 = "ipv6"
+)
+
+// validICMPFamily reports whether the given ICMP address family is supported. An empty family is valid and means
+// that the first resolved address is used regardless of its family.
+func validICMPFamily(family string) bool {
+	switch strings.ToLower(family) {
+	case "", icmpFamilyIPv4, icmpFamilyIPv6:
+		return true
+	default:
+		return false
+	}
+}
+
+// selectICMPAddr returns the first of the given addresses which belongs to the given address family. If the family
+// is empty, the first address is returned. A non-nil error is returned if no address matches.
+func selectICMPAddr(addrs []net.IPAddr, family string) (net.IPAddr, error) {
+	family = strings.ToLower(family)
+
+	for _, addr := range addrs {
+		isIPv4 := addr.IP.To4() != nil
+
+		switch {
+		case family == "",
+			family == icmpFamilyIPv4 && isIPv4,
+			family == icmpFamilyIPv6 && !isIPv4:
+			return addr, nil
+		}
+	}
+
+	if family == "" {
+		return net.IPAddr{}, errors.New("host did not resolve to any address")
+	}
+
+	return net.IPAddr{}, fmt.Errorf("host did not resolve to any %s address", family)
+}
+
+// isReplyFrom reports whether a reply received from the given socket address was sent by the given IP address.
+func isReplyFrom(from syscall.Sockaddr, ip net.IP) bool {
+	switch sa := from.(type) {
+	case *syscall.SockaddrInet4:
+		return ip.To4() != nil && net.IP(sa.Addr[:]).Equal(ip)
+	case *syscall.SockaddrInet6:
+		return ip.To4() == nil && net.IP(sa.Addr[:]).Equal(ip)
+	default:
+		return false
+	}
+}
+
+// defaultICMPInterval is the interval between echo requests of an ICMP check used if none is set on the socket.
+const defaultICMPInterval = time.Second
+
+// icmpCount returns the number of echo requests to send for the given socket.
+func icmpCount(sock socket.Socket) int {
+	if sock.ICMPCount > 0 {
+		return sock.ICMPCount
+	}
+
+	return 1
+}
+
+// icmpInterval returns the interval between echo requests for the given socket.
+func icmpInterval(sock socket.Socket) time.Duration {
+	if sock.ICMPIntervalMs > 0 {
+		return time.Duration(sock.ICMPIntervalMs) * time.Millisecond
+	}
+
+	return defaultICMPInterval
+}
+
+// newICMPStats computes the statistics of an ICMP check from the number of sent echo requests and the round-trip
+// times of the received replies. The mean deviation is computed the same way as by ping.
+func newICMPStats(sent int, rtts []time.Duration) *socket.ICMPStats {
+	stats := &socket.ICMPStats{Sent: sent, Received: len(rtts)}
+
+	if sent > 0 {
+		stats.PacketLoss = float64(sent-len(rtts)) / float64(sent) * 100
+	}
+
+	if len(rtts) == 0 {
+		return stats
+	}
+
+	stats.MinRTT, stats.MaxRTT = rtts[0], rtts[0]
+
+	var sum, sumSquares float64
+	for _, rtt := range rtts {
+		stats.MinRTT = min(stats.MinRTT, rtt)
+		stats.MaxRTT = max(stats.MaxRTT, rtt)
+
+		sum += float64(rtt)
+		sumSquares += float64(rtt) * float64(rtt)
+	}
+
+	n := float64(len(rtts))
+	avg := sum / n
+
+	stats.AvgRTT = time.Duration(avg)
+	stats.MdevRTT = time.Duration(math.Sqrt(math.Max(sumSquares/n-avg*avg, 0)))
+
+	return stats
+}
+
+// checkPacketLoss returns a non-nil error if no echo reply was received or if the packet loss exceeds the given
+// limit in percent. A limit of zero is not applied.
+func checkPacketLoss(stats *socket.ICMPStats, limit float64) error {
+	if stats.Received == 0 {
+		return fmt.Errorf("no echo reply received to %d echo requests", stats.Sent)
+	}
+
+	if limit > 0 && stats.PacketLoss > limit {
+		return fmt.Errorf(
+			"packet loss %.1f%% exceeded the limit of %.1f%% (%d of %d replies received)",
+			stats.PacketLoss, limit, stats.Received, stats.Sent,
+		)
+	}
+
+	return nil
+}
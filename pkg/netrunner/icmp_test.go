@@ -0,0 +1,299 @@
+package netrunner
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+func TestSelectICMPAddr(t *testing.T) {
+	v4 := net.IPAddr{IP: net.ParseIP("192.0.2.1")}
+	v6 := net.IPAddr{IP: net.ParseIP("2001:db8::1")}
+
+	tests := []struct {
+		name    string
+		addrs   []net.IPAddr
+		family  string
+		want    net.IPAddr
+		wantErr bool
+	}{
+		{
+			name:  "returns the first address without a family",
+			addrs: []net.IPAddr{v6, v4},
+			want:  v6,
+		},
+		{
+			name:   "returns the first IPv4 address",
+			addrs:  []net.IPAddr{v6, v4},
+			family: "ipv4",
+			want:   v4,
+		},
+		{
+			name:   "returns the first IPv6 address",
+			addrs:  []net.IPAddr{v4, v6},
+			family: "IPv6",
+			want:   v6,
+		},
+		{
+			name:    "fails when no address of the family is resolved",
+			addrs:   []net.IPAddr{v4},
+			family:  "ipv6",
+			wantErr: true,
+		},
+		{
+			name:    "fails when no address is resolved",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectICMPAddr(tt.addrs, tt.family)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectICMPAddr() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !got.IP.Equal(tt.want.IP) {
+				t.Errorf("selectICMPAddr() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewICMPRunner_AddressFamily(t *testing.T) {
+	for _, family := range []string{"", "ipv4", "ipv6", "IPv6"} {
+		if _, err := newICMPRunner(socket.Socket{Host: "localhost", ICMPAddressFamily: family}, &MockLogger{}); err != nil {
+			t.Errorf("newICMPRunner(): unexpected error for family %q: %v", family, err)
+		}
+	}
+
+	if _, err := newICMPRunner(socket.Socket{Host: "localhost", ICMPAddressFamily: "ipx"}, &MockLogger{}); err == nil {
+		t.Error("newICMPRunner(): expected an error for an unsupported address family")
+	}
+}
+
+func TestNewICMPRunner_PacketOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		sock    socket.Socket
+		wantErr bool
+	}{
+		{
+			name: "accepts a count, an interval and a packet loss limit",
+			sock: socket.Socket{Host: "localhost", ICMPCount: 5, ICMPIntervalMs: 200, MaxPacketLossPercent: 20},
+		},
+		{
+			name:    "fails on a negative count",
+			sock:    socket.Socket{Host: "localhost", ICMPCount: -1},
+			wantErr: true,
+		},
+		{
+			name:    "fails on a negative interval",
+			sock:    socket.Socket{Host: "localhost", ICMPIntervalMs: -1},
+			wantErr: true,
+		},
+		{
+			name:    "fails on a packet loss limit over 100 percent",
+			sock:    socket.Socket{Host: "localhost", MaxPacketLossPercent: 101},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newICMPRunner(tt.sock, &MockLogger{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newICMPRunner() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewICMPStats(t *testing.T) {
+	ms := time.Millisecond
+
+	tests := []struct {
+		name string
+		sent int
+		rtts []time.Duration
+		want *socket.ICMPStats
+	}{
+		{
+			name: "computes the statistics of all received replies",
+			sent: 4,
+			rtts: []time.Duration{10 * ms, 30 * ms, 10 * ms, 30 * ms},
+			want: &socket.ICMPStats{
+				Sent: 4, Received: 4, PacketLoss: 0,
+				MinRTT: 10 * ms, AvgRTT: 20 * ms, MaxRTT: 30 * ms, MdevRTT: 10 * ms,
+			},
+		},
+		{
+			name: "computes the packet loss",
+			sent: 4,
+			rtts: []time.Duration{10 * ms},
+			want: &socket.ICMPStats{
+				Sent: 4, Received: 1, PacketLoss: 75,
+				MinRTT: 10 * ms, AvgRTT: 10 * ms, MaxRTT: 10 * ms,
+			},
+		},
+		{
+			name: "leaves the round-trip times empty without replies",
+			sent: 2,
+			want: &socket.ICMPStats{Sent: 2, PacketLoss: 100},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newICMPStats(tt.sent, tt.rtts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newICMPStats() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPacketLoss(t *testing.T) {
+	tests := []struct {
+		name    string
+		stats   *socket.ICMPStats
+		limit   float64
+		wantErr bool
+	}{
+		{
+			name:  "passes on partial loss without a limit",
+			stats: &socket.ICMPStats{Sent: 5, Received: 1, PacketLoss: 80},
+		},
+		{
+			name:  "passes on loss within the limit",
+			stats: &socket.ICMPStats{Sent: 5, Received: 4, PacketLoss: 20},
+			limit: 20,
+		},
+		{
+			name:    "fails on loss over the limit",
+			stats:   &socket.ICMPStats{Sent: 5, Received: 3, PacketLoss: 40},
+			limit:   20,
+			wantErr: true,
+		},
+		{
+			name:    "fails when no reply is received",
+			stats:   &socket.ICMPStats{Sent: 1, PacketLoss: 100},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkPacketLoss(tt.stats, tt.limit); (err != nil) != tt.wantErr {
+				t.Errorf("checkPacketLoss() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIcmpRunner_RunTest_IPv6(t *testing.T) {
+	if runtime.GOOS == osWindows {
+		t.Skip("ICMP tests are skipped on Windows")
+	}
+
+	runner := icmpRunner{
+		logger: &MockLogger{},
+	}
+
+	t.Run("fails when the host has no address of the requested family", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		got := runner.RunTest(ctx, socket.Socket{ID: "v4_only", Host: "127.0.0.1", ICMPAddressFamily: "ipv6"})
+		if got.Passed || got.Error == nil {
+			t.Errorf("expected a failure for an IPv4 only host, got passed = %v, error = %v", got.Passed, got.Error)
+		}
+	})
+
+	t.Run("pings the IPv6 loopback address", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		got := runner.RunTest(ctx, socket.Socket{ID: "v6_loopback", Host: "::1"})
+		if !got.Passed {
+			t.Logf("IPv6 loopback failed (expected if IPv6 or non-privileged ICMP is unavailable): %v", got.Error)
+		}
+	})
+}
+
+func TestIcmpRunner_RunTest_MultiplePackets(t *testing.T) {
+	if runtime.GOOS == osWindows {
+		t.Skip("ICMP tests are skipped on Windows")
+	}
+
+	runner := icmpRunner{
+		logger: &MockLogger{},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sock := socket.Socket{ID: "loopback", Host: "127.0.0.1", ICMPCount: 3, ICMPIntervalMs: 50, MaxPacketLossPercent: 50}
+
+	got := runner.RunTest(ctx, sock)
+	if !got.Passed {
+		t.Logf("loopback failed (expected if non-privileged ICMP is unavailable): %v", got.Error)
+		return
+	}
+
+	if got.ICMPStats == nil || got.ICMPStats.Sent != 3 || got.ICMPStats.Received != 3 {
+		t.Errorf("expected 3 of 3 replies to be received, got %+v", got.ICMPStats)
+	}
+}
+
+func TestIcmpRunner_RunTest_PacketLoss(t *testing.T) {
+	if runtime.GOOS == osWindows {
+		t.Skip("ICMP tests are skipped on Windows")
+	}
+
+	runner := icmpRunner{
+		logger: &MockLogger{},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 203.0.113.0/24 (TEST-NET-3) is reserved for documentation and is not expected to reply.
+	sock := socket.Socket{ID: "test_net", Host: "203.0.113.1", ICMPCount: 2, ICMPIntervalMs: 50}
+
+	got := runner.RunTest(ctx, sock)
+	if got.Passed {
+		t.Fatalf("expected a failure when no echo reply is received, stats: %+v", got.ICMPStats)
+	}
+
+	if got.ICMPStats != nil && got.ICMPStats.Received != 0 {
+		t.Errorf("expected no replies to be received, got %+v", got.ICMPStats)
+	}
+}
+
+func TestIsReplyFrom(t *testing.T) {
+	tests := []struct {
+		name string
+		from syscall.Sockaddr
+		ip   string
+		want bool
+	}{
+		{name: "same IPv4 address", from: &syscall.SockaddrInet4{Addr: [4]byte{192, 0, 2, 1}}, ip: "192.0.2.1", want: true},
+		{name: "other IPv4 address", from: &syscall.SockaddrInet4{Addr: [4]byte{192, 0, 2, 2}}, ip: "192.0.2.1"},
+		{name: "same IPv6 address", from: &syscall.SockaddrInet6{Addr: [16]byte(net.ParseIP("2001:db8::1").To16())}, ip: "2001:db8::1", want: true},
+		{name: "other IPv6 address", from: &syscall.SockaddrInet6{Addr: [16]byte(net.ParseIP("2001:db8::2").To16())}, ip: "2001:db8::1"},
+		{name: "IPv4-mapped IPv6 address", from: &syscall.SockaddrInet6{Addr: [16]byte(net.ParseIP("::ffff:192.0.2.1").To16())}, ip: "192.0.2.1"},
+		{name: "no address", from: nil, ip: "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		if got := isReplyFrom(tt.from, net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("isReplyFrom(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
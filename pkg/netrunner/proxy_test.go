@@ -0,0 +1,425 @@
+package netrunner
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go.vxn.dev/dish/pkg/config"
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+// testProxy records the targets requested through a test proxy.
+type testProxy struct {
+	mu      sync.Mutex
+	targets []string
+}
+
+func (p *testProxy) record(target string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.targets = append(p.targets, target)
+}
+
+func (p *testProxy) requested() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]string(nil), p.targets...)
+}
+
+// tunnel connects the client connection to the backend address and copies data in both directions until either
+// side closes its connection.
+func tunnel(client net.Conn, backend string) {
+	defer client.Close() //nolint:errcheck
+
+	conn, err := net.Dial("tcp", backend)
+	if err != nil {
+		return
+	}
+	defer conn.Close() //nolint:errcheck
+
+	go func() {
+		_, _ = io.Copy(conn, client)
+	}()
+	_, _ = io.Copy(client, conn)
+}
+
+// newTestHTTPProxy starts a HTTP proxy requiring the given credentials for proxy auth, unless empty. CONNECT
+// requests are tunnelled to the backend address regardless of the requested target. Other requests are answered
+// by the proxy itself with 200 OK. The proxy URL is returned and the proxy is closed when the test finishes.
+func newTestHTTPProxy(t *testing.T, user, password, backend string) (string, *testProxy) {
+	t.Helper()
+
+	proxy := &testProxy{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user != "" {
+			req := &http.Request{Header: http.Header{"Authorization": r.Header["Proxy-Authorization"]}}
+			if u, p, ok := req.BasicAuth(); !ok || u != user || p != password {
+				w.WriteHeader(http.StatusProxyAuthRequired)
+				return
+			}
+		}
+
+		if r.Method != http.MethodConnect {
+			proxy.record(r.URL.String())
+			return
+		}
+
+		proxy.record(r.Host)
+
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+
+		if _, err := conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+			_ = conn.Close()
+			return
+		}
+
+		tunnel(conn, backend)
+	}))
+	t.Cleanup(server.Close)
+
+	u, _ := url.Parse(server.URL)
+	if user != "" {
+		u.User = url.UserPassword(user, password)
+	}
+
+	return u.String(), proxy
+}
+
+// newTestSOCKS5Proxy starts a SOCKS5 proxy requiring the given credentials, unless empty. All CONNECT requests
+// are tunnelled to the backend address regardless of the requested target. The proxy URL is returned and the proxy
+// is closed when the test finishes.
+func newTestSOCKS5Proxy(t *testing.T, user, password, backend string) (string, *testProxy) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start a TCP listener: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+
+	proxy := &testProxy{}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				target, ok := socks5Handshake(conn, user, password)
+				if !ok {
+					_ = conn.Close()
+					return
+				}
+
+				proxy.record(target)
+				tunnel(conn, backend)
+			}()
+		}
+	}()
+
+	u := &url.URL{Scheme: "socks5", Host: ln.Addr().String()}
+	if user != "" {
+		u.User = url.UserPassword(user, password)
+	}
+
+	return u.String(), proxy
+}
+
+// socks5Handshake negotiates the authentication method and reads the CONNECT request of a SOCKS5 client (RFC 1928
+// and RFC 1929). It returns the requested target address and whether the handshake succeeded.
+func socks5Handshake(conn net.Conn, user, password string) (string, bool) {
+	buf := make([]byte, 256)
+
+	// Greeting: version, number of methods, methods
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil || buf[0] != 5 {
+		return "", false
+	}
+	if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+		return "", false
+	}
+
+	if user == "" {
+		if _, err := conn.Write([]byte{5, 0}); err != nil {
+			return "", false
+		}
+	} else {
+		if _, err := conn.Write([]byte{5, 2}); err != nil {
+			return "", false
+		}
+
+		// Username/password auth: version, username length, username, password length, password
+		if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+			return "", false
+		}
+		gotUser := make([]byte, buf[1])
+		if _, err := io.ReadFull(conn, gotUser); err != nil {
+			return "", false
+		}
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return "", false
+		}
+		gotPassword := make([]byte, buf[0])
+		if _, err := io.ReadFull(conn, gotPassword); err != nil {
+			return "", false
+		}
+
+		if string(gotUser) != user || string(gotPassword) != password {
+			_, _ = conn.Write([]byte{1, 1})
+			return "", false
+		}
+		if _, err := conn.Write([]byte{1, 0}); err != nil {
+			return "", false
+		}
+	}
+
+	// Request: version, command, reserved, address type, address, port
+	if _, err := io.ReadFull(conn, buf[:4]); err != nil || buf[1] != 1 {
+		return "", false
+	}
+
+	var host string
+	switch buf[3] {
+	case 1:
+		if _, err := io.ReadFull(conn, buf[:net.IPv4len]); err != nil {
+			return "", false
+		}
+		host = net.IP(buf[:net.IPv4len]).String()
+	case 3:
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return "", false
+		}
+		n := int(buf[0])
+		if _, err := io.ReadFull(conn, buf[:n]); err != nil {
+			return "", false
+		}
+		host = string(buf[:n])
+	case 4:
+		if _, err := io.ReadFull(conn, buf[:net.IPv6len]); err != nil {
+			return "", false
+		}
+		host = net.IP(buf[:net.IPv6len]).String()
+	default:
+		return "", false
+	}
+
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return "", false
+	}
+	port := binary.BigEndian.Uint16(buf[:2])
+
+	if _, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+		return "", false
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(int(port))), true
+}
+
+func TestHttpRunner_RunTest_Proxy(t *testing.T) {
+	cert := newTestCertificate(t, time.Now().Add(24*time.Hour))
+	caFile, _ := writeTestCertificate(t, cert)
+
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	backend.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	backend.StartTLS()
+	defer backend.Close()
+
+	backendAddr := backend.Listener.Addr().String()
+
+	httpProxy, httpProxyRequests := newTestHTTPProxy(t, "", "", backendAddr)
+	authProxy, authProxyRequests := newTestHTTPProxy(t, "dish", "s3cret", backendAddr)
+	socksProxy, socksProxyRequests := newTestSOCKS5Proxy(t, "", "", backendAddr)
+	socksAuthProxy, socksAuthProxyRequests := newTestSOCKS5Proxy(t, "dish", "s3cret", backendAddr)
+
+	wrongCredentials := func(proxy string) string {
+		u, _ := url.Parse(proxy)
+		u.User = url.UserPassword("dish", "wrong")
+		return u.String()
+	}
+
+	// The proxies connect to the backend regardless of the requested target, so the checks only pass if they
+	// are made through the proxy: backend.test does not resolve and nothing listens on port 443 of localhost.
+	tests := []struct {
+		name       string
+		host       string
+		proxy      string
+		requests   *testProxy
+		wantTarget string
+		wantPass   bool
+	}{
+		{
+			name:       "HTTP request through a HTTP proxy",
+			host:       "http://backend.test",
+			proxy:      httpProxy,
+			requests:   httpProxyRequests,
+			wantTarget: "http://backend.test:80/",
+			wantPass:   true,
+		},
+		{
+			name:       "HTTPS request tunnelled through a HTTP proxy with auth",
+			host:       "https://localhost",
+			proxy:      authProxy,
+			requests:   authProxyRequests,
+			wantTarget: "localhost:443",
+			wantPass:   true,
+		},
+		{
+			name:     "HTTP proxy with wrong credentials",
+			host:     "http://backend.test",
+			proxy:    wrongCredentials(authProxy),
+			requests: &testProxy{},
+		},
+		{
+			name:       "HTTPS request through a SOCKS5 proxy",
+			host:       "https://localhost",
+			proxy:      socksProxy,
+			requests:   socksProxyRequests,
+			wantTarget: "localhost:443",
+			wantPass:   true,
+		},
+		{
+			name:       "HTTPS request through a SOCKS5 proxy with auth",
+			host:       "https://localhost",
+			proxy:      socksAuthProxy,
+			requests:   socksAuthProxyRequests,
+			wantTarget: "localhost:443",
+			wantPass:   true,
+		},
+		{
+			name:     "SOCKS5 proxy with wrong credentials",
+			host:     "https://localhost",
+			proxy:    wrongCredentials(socksAuthProxy),
+			requests: &testProxy{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := 80
+			if u, _ := url.Parse(tt.host); u.Scheme == "https" {
+				port = 443
+			}
+
+			sock := socket.Socket{
+				ID:                "proxied",
+				Host:              tt.host,
+				Port:              port,
+				PathHTTP:          "/",
+				ExpectedHTTPCodes: []int{200},
+				TLSCAFile:         caFile,
+				Proxy:             tt.proxy,
+			}
+
+			runner, err := newHTTPRunner(sock, &MockLogger{})
+			if err != nil {
+				t.Fatalf("newHTTPRunner(): unexpected error: %v", err)
+			}
+
+			got := runner.RunTest(context.Background(), sock)
+			if got.Passed != tt.wantPass {
+				t.Errorf("httpRunner.RunTest(): expected passed = %v, got %v (error: %v)", tt.wantPass, got.Passed, got.Error)
+			}
+
+			if tt.wantTarget == "" {
+				return
+			}
+
+			if targets := tt.requests.requested(); len(targets) == 0 || targets[len(targets)-1] != tt.wantTarget {
+				t.Errorf("expected the proxy to be asked for %s, got %v", tt.wantTarget, targets)
+			}
+		})
+	}
+}
+
+func TestNewHTTPRunner_Proxy(t *testing.T) {
+	tests := []struct {
+		name string
+		sock socket.Socket
+	}{
+		{
+			name: "unsupported proxy scheme",
+			sock: socket.Socket{Host: "https://example.com", Proxy: "ftp://proxy.example.com"},
+		},
+		{
+			name: "proxy combined with connect_to",
+			sock: socket.Socket{Host: "https://example.com", Proxy: "http://proxy.example.com", ConnectTo: "203.0.113.10"},
+		},
+		{
+			name: "proxy combined with all_addresses",
+			sock: socket.Socket{Host: "https://example.com", Proxy: "http://proxy.example.com", AllAddresses: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newHTTPRunner(tt.sock, &MockLogger{}); err == nil {
+				t.Errorf("newHTTPRunner(): expected an error, got nil")
+			}
+		})
+	}
+}
+
+func TestRunSocket_GlobalProxy(t *testing.T) {
+	proxy, requests := newTestHTTPProxy(t, "", "", "")
+
+	sock := socket.Socket{ID: "proxied", Host: "http://backend.test", Port: 80, PathHTTP: "/health", ExpectedHTTPCodes: []int{200}}
+
+	got, err := RunSocket(context.Background(), sock, &config.Config{TimeoutSeconds: 5, Proxy: proxy}, &MockLogger{})
+	if err != nil {
+		t.Fatalf("RunSocket(): unexpected error: %v", err)
+	}
+
+	if !got.Passed {
+		t.Errorf("RunSocket(): expected the check to pass through the global proxy, got error: %v", got.Error)
+	}
+
+	if targets := requests.requested(); len(targets) != 1 || targets[0] != "http://backend.test:80/health" {
+		t.Errorf("expected the proxy to be asked for http://backend.test:80/health, got %v", targets)
+	}
+}
+
+func TestRunSocket_GlobalProxyWithConnectTo(t *testing.T) {
+	proxy, requests := newTestHTTPProxy(t, "", "", "")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	// The global proxy is skipped, so the check connects to the connect_to address directly instead of failing.
+	sock := socket.Socket{ID: "pinned", Host: "http://backend.test", Port: port, ConnectTo: u.Host, ExpectedHTTPCodes: []int{200}}
+
+	got, err := RunSocket(context.Background(), sock, &config.Config{TimeoutSeconds: 5, Proxy: proxy}, &MockLogger{})
+	if err != nil {
+		t.Fatalf("RunSocket(): unexpected error: %v", err)
+	}
+
+	if !got.Passed {
+		t.Errorf("RunSocket(): expected the check to pass without the global proxy, got error: %v", got.Error)
+	}
+
+	if targets := requests.requested(); len(targets) != 0 {
+		t.Errorf("expected the global proxy not to be used, got requests for %v", targets)
+	}
+}
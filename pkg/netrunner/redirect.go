@@ -0,0 +1,75 @@
+package netrunner
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+// validateRedirects checks the redirect options of the given socket.
+func validateRedirects(sock socket.Socket) error {
+	if sock.MaxRedirects < 0 {
+		return errors.New("max_redirects cannot be negative")
+	}
+
+	if sock.DisableRedirects && sock.MaxRedirects > 0 {
+		return errors.New("max_redirects cannot be combined with disable_redirects")
+	}
+
+	if sock.ExpectedFinalURL != "" {
+		if u, err := url.Parse(sock.ExpectedFinalURL); err != nil || !u.IsAbs() {
+			return errors.New("expected_final_url must be an absolute URL")
+		}
+	}
+
+	return nil
+}
+
+// redirectPolicy returns the function deciding whether the HTTP client follows a redirect for the given
+// socket. If redirects are disabled, the redirect response is returned as is. If a maximum number of
+// redirects is set, the request fails once it is exceeded. A nil function is returned if neither is set,
+// so that the default policy of following up to 10 redirects applies.
+func redirectPolicy(sock socket.Socket) func(req *http.Request, via []*http.Request) error {
+	if sock.DisableRedirects {
+		return func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+
+	if sock.MaxRedirects > 0 {
+		return func(req *http.Request, via []*http.Request) error {
+			if len(via) > sock.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", sock.MaxRedirects)
+			}
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// defaultPorts maps the URL schemes to their default ports.
+var defaultPorts = map[string]string{"http": "80", "https": "443"}
+
+// sameURL reports whether the two URLs are equal, treating an empty path as "/", comparing the scheme
+// and host case-insensitively and ignoring the default port of the scheme, e.g. ":443" for https.
+func sameURL(a, b *url.URL) bool {
+	normalize := func(u *url.URL) string {
+		n := *u
+		n.Scheme = strings.ToLower(n.Scheme)
+		n.Host = strings.ToLower(n.Host)
+		if port := n.Port(); port != "" && port == defaultPorts[n.Scheme] {
+			n.Host = strings.TrimSuffix(n.Host, ":"+port)
+		}
+		if n.Path == "" && n.Opaque == "" {
+			n.Path = "/"
+		}
+		return n.String()
+	}
+
+	return normalize(a) == normalize(b)
+}
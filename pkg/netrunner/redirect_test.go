@@ -0,0 +1,242 @@
+package netrunner
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+func TestHttpRunner_RunTest_Redirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/chain/{n}", func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(r.PathValue("n"))
+		if n > 0 {
+			http.Redirect(w, r, "/chain/"+strconv.Itoa(n-1), http.StatusFound)
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// Redirects to another host, like an apex to www redirect.
+	u, _ := url.Parse(server.URL)
+	otherHost := "http://localhost:" + u.Port()
+	mux.HandleFunc("/apex", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, otherHost+"/new", http.StatusMovedPermanently)
+	})
+
+	tests := []struct {
+		name      string
+		path      string
+		codes     []int
+		modify    func(sock *socket.Socket)
+		wantPass  bool
+		wantError string
+	}{
+		{
+			name:     "redirects are followed by default",
+			path:     "/old",
+			codes:    []int{200},
+			modify:   func(sock *socket.Socket) { sock.ExpectedFinalURL = server.URL + "/new" },
+			wantPass: true,
+		},
+		{
+			name:      "redirect response cannot be expected when redirects are followed",
+			path:      "/old",
+			codes:     []int{301},
+			modify:    func(sock *socket.Socket) {},
+			wantError: "expected codes: [301], got 200",
+		},
+		{
+			name:  "redirect response with a relative location",
+			path:  "/old",
+			codes: []int{301},
+			modify: func(sock *socket.Socket) {
+				sock.DisableRedirects = true
+				sock.ExpectedLocation = "/new"
+			},
+			wantPass: true,
+		},
+		{
+			name:  "redirect response with an absolute location",
+			path:  "/old",
+			codes: []int{301},
+			modify: func(sock *socket.Socket) {
+				sock.DisableRedirects = true
+				sock.ExpectedLocation = server.URL + "/new"
+			},
+			wantPass: true,
+		},
+		{
+			name:  "unexpected location",
+			path:  "/old",
+			codes: []int{301},
+			modify: func(sock *socket.Socket) {
+				sock.DisableRedirects = true
+				sock.ExpectedLocation = "/other"
+			},
+			wantError: `expected Location header "/other", got "/new"`,
+		},
+		{
+			name:      "missing location",
+			path:      "/new",
+			codes:     []int{200},
+			modify:    func(sock *socket.Socket) { sock.ExpectedLocation = "/new" },
+			wantError: `expected Location header "/new", got none`,
+		},
+		{
+			name:     "redirect to another host",
+			path:     "/apex",
+			codes:    []int{200},
+			modify:   func(sock *socket.Socket) { sock.ExpectedFinalURL = otherHost + "/new" },
+			wantPass: true,
+		},
+		{
+			name:      "unexpected final URL",
+			path:      "/apex",
+			codes:     []int{200},
+			modify:    func(sock *socket.Socket) { sock.ExpectedFinalURL = server.URL + "/new" },
+			wantError: "expected final URL",
+		},
+		{
+			name:     "redirects within the limit",
+			path:     "/chain/3",
+			codes:    []int{200},
+			modify:   func(sock *socket.Socket) { sock.MaxRedirects = 3 },
+			wantPass: true,
+		},
+		{
+			name:      "redirects over the limit",
+			path:      "/chain/3",
+			codes:     []int{200},
+			modify:    func(sock *socket.Socket) { sock.MaxRedirects = 2 },
+			wantError: "stopped after 2 redirects",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sock := newTestServerSocket(t, server)
+			sock.PathHTTP = tt.path
+			sock.ExpectedHTTPCodes = tt.codes
+			tt.modify(&sock)
+
+			runner, err := newHTTPRunner(sock, &MockLogger{})
+			if err != nil {
+				t.Fatalf("newHTTPRunner(): unexpected error: %v", err)
+			}
+
+			got := runner.RunTest(context.Background(), sock)
+			if got.Passed != tt.wantPass {
+				t.Errorf("httpRunner.RunTest(): expected passed = %v, got %v (error: %v)", tt.wantPass, got.Passed, got.Error)
+			}
+
+			if tt.wantError != "" && (got.Error == nil || !strings.Contains(got.Error.Error(), tt.wantError)) {
+				t.Errorf("httpRunner.RunTest(): expected an error containing %q, got %v", tt.wantError, got.Error)
+			}
+		})
+	}
+}
+
+func TestHttpRunner_RunTest_RedirectsDefaultPort(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		}
+	}))
+	defer server.Close()
+
+	// The request URL includes the default port of the scheme, which the expected URLs leave out.
+	sock := socket.Socket{
+		ID:                "default_port",
+		Host:              "http://example.test",
+		Port:              80,
+		PathHTTP:          "/old",
+		ExpectedHTTPCodes: []int{200},
+		ExpectedFinalURL:  "http://example.test/new",
+		ConnectTo:         server.Listener.Addr().String(),
+	}
+
+	for _, modify := range []func(sock *socket.Socket){
+		func(sock *socket.Socket) {},
+		func(sock *socket.Socket) {
+			sock.DisableRedirects = true
+			sock.ExpectedHTTPCodes = []int{301}
+			sock.ExpectedFinalURL = "http://example.test/old"
+			sock.ExpectedLocation = "http://example.test/new"
+		},
+	} {
+		sock := sock
+		modify(&sock)
+
+		runner, err := newHTTPRunner(sock, &MockLogger{})
+		if err != nil {
+			t.Fatalf("newHTTPRunner(): unexpected error: %v", err)
+		}
+
+		if got := runner.RunTest(context.Background(), sock); !got.Passed {
+			t.Errorf("httpRunner.RunTest(): expected the check to pass, got error: %v", got.Error)
+		}
+	}
+}
+
+func TestValidateRedirects(t *testing.T) {
+	tests := []struct {
+		name    string
+		sock    socket.Socket
+		wantErr bool
+	}{
+		{name: "defaults", sock: socket.Socket{}},
+		{name: "redirects disabled", sock: socket.Socket{DisableRedirects: true, ExpectedLocation: "/new"}},
+		{name: "maximum redirects", sock: socket.Socket{MaxRedirects: 1, ExpectedFinalURL: "https://www.example.com/"}},
+		{name: "negative maximum redirects", sock: socket.Socket{MaxRedirects: -1}, wantErr: true},
+		{name: "maximum redirects with redirects disabled", sock: socket.Socket{DisableRedirects: true, MaxRedirects: 1}, wantErr: true},
+		{name: "relative final URL", sock: socket.Socket{ExpectedFinalURL: "/new"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateRedirects(tt.sock); (err != nil) != tt.wantErr {
+				t.Errorf("validateRedirects() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSameURL(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "https://www.example.com", b: "https://www.example.com/", want: true},
+		{a: "HTTPS://WWW.example.com/path", b: "https://www.example.com/path", want: true},
+		{a: "https://www.example.com/path?q=1", b: "https://www.example.com/path?q=1", want: true},
+		{a: "http://www.example.com/", b: "https://www.example.com/", want: false},
+		{a: "https://example.com/", b: "https://www.example.com/", want: false},
+		{a: "https://www.example.com/Path", b: "https://www.example.com/path", want: false},
+		{a: "https://example.com:443/new", b: "https://example.com/new", want: true},
+		{a: "http://example.com:80/new", b: "http://example.com/new", want: true},
+		{a: "https://[::1]:443/", b: "https://[::1]/", want: true},
+		{a: "https://example.com:80/new", b: "https://example.com/new", want: false},
+		{a: "https://example.com:8443/new", b: "https://example.com/new", want: false},
+	}
+
+	for _, tt := range tests {
+		a, _ := url.Parse(tt.a)
+		b, _ := url.Parse(tt.b)
+
+		if got := sameURL(a, b); got != tt.want {
+			t.Errorf("sameURL(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
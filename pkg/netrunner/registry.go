@@ -0,0 +1,67 @@
+package netrunner
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"go.vxn.dev/dish/pkg/logger"
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+// Factory creates a new NetRunner for the given socket. It should return a non-nil error if the socket
+// cannot be tested by the runner (e.g. a required field is missing).
+type Factory func(sock socket.Socket, logger logger.Logger) (NetRunner, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+// Register makes a NetRunner factory available for sockets using the provided protocol. Protocol names are
+// case-insensitive. Register is intended to be called from the init function of the package implementing the runner.
+//
+// Register panics if the factory is nil or if a factory is already registered for the protocol.
+func Register(protocol string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+
+	protocol = strings.ToLower(protocol)
+
+	if protocol == "" {
+		panic("netrunner: Register protocol is empty")
+	}
+
+	if factory == nil {
+		panic("netrunner: Register factory is nil for protocol " + protocol)
+	}
+
+	if _, dup := factories[protocol]; dup {
+		panic("netrunner: Register called twice for protocol " + protocol)
+	}
+
+	factories[protocol] = factory
+}
+
+// Protocols returns a sorted list of the protocols for which a NetRunner factory is registered.
+func Protocols() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	protocols := make([]string, 0, len(factories))
+	for protocol := range factories {
+		protocols = append(protocols, protocol)
+	}
+	slices.Sort(protocols)
+
+	return protocols
+}
+
+// lookupFactory returns the NetRunner factory registered for the provided protocol, if any.
+func lookupFactory(protocol string) (Factory, bool) {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	factory, ok := factories[strings.ToLower(protocol)]
+	return factory, ok
+}
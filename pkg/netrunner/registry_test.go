@@ -0,0 +1,103 @@
+package netrunner
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"go.vxn.dev/dish/pkg/logger"
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+// customRunner is a NetRunner used to test the registration of custom runners.
+type customRunner struct{}
+
+func (customRunner) RunTest(_ context.Context, sock socket.Socket) socket.Result {
+	return socket.Result{Socket: sock, Passed: true}
+}
+
+func TestRegister(t *testing.T) {
+	Register("Custom-Test", func(sock socket.Socket, _ logger.Logger) (NetRunner, error) {
+		return customRunner{}, nil
+	})
+
+	t.Cleanup(func() {
+		factoriesMu.Lock()
+		delete(factories, "custom-test")
+		factoriesMu.Unlock()
+	})
+
+	if !slices.Contains(Protocols(), "custom-test") {
+		t.Fatalf("Protocols(): expected the registered protocol to be listed, got %v", Protocols())
+	}
+
+	sock := socket.Socket{ID: "custom", Host: "https://example.com", Protocol: "custom-test"}
+
+	runner, err := NewNetRunner(sock, &MockLogger{})
+	if err != nil {
+		t.Fatalf("NewNetRunner(): unexpected error: %v", err)
+	}
+
+	if _, ok := runner.(customRunner); !ok {
+		t.Fatalf("NewNetRunner(): expected a customRunner, got %T", runner)
+	}
+}
+
+func TestRegister_Panics(t *testing.T) {
+	factory := func(sock socket.Socket, _ logger.Logger) (NetRunner, error) {
+		return customRunner{}, nil
+	}
+
+	tests := []struct {
+		name     string
+		protocol string
+		factory  Factory
+	}{
+		{
+			name:     "panics on a duplicate protocol",
+			protocol: ProtocolHTTP,
+			factory:  factory,
+		},
+		{
+			name:     "panics on a duplicate protocol with a different case",
+			protocol: "TCP",
+			factory:  factory,
+		},
+		{
+			name:     "panics on a nil factory",
+			protocol: "nil-factory",
+			factory:  nil,
+		},
+		{
+			name:     "panics on an empty protocol",
+			protocol: "",
+			factory:  factory,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("Register(): expected a panic")
+				}
+			}()
+
+			Register(tt.protocol, tt.factory)
+		})
+	}
+}
+
+func TestProtocols(t *testing.T) {
+	got := Protocols()
+
+	for _, protocol := range []string{ProtocolHTTP, ProtocolICMP, ProtocolTCP} {
+		if !slices.Contains(got, protocol) {
+			t.Errorf("Protocols(): expected %s to be registered, got %v", protocol, got)
+		}
+	}
+
+	if !slices.IsSorted(got) {
+		t.Errorf("Protocols(): expected a sorted list, got %v", got)
+	}
+}
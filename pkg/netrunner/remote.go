@@ -0,0 +1,67 @@
+package netrunner
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"go.vxn.dev/dish/pkg/config"
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+// checkRemoteReferences checks that the environment variables and files referenced by a socket loaded from the
+// remote API are allowed by the config. Whoever controls the remote socket list could otherwise have any secret or
+// file of the host running dish sent to a server of their choice. Secrets can only be read from environment
+// variables whose names start with cfg.RemoteEnvPrefix, and files only from within cfg.RemoteFileDir. No
+// environment variables or files are allowed if the respective setting is empty.
+func checkRemoteReferences(sock socket.Socket, cfg *config.Config) error {
+	files := []string{sock.BodyFileHTTP, sock.TLSCAFile, sock.TLSCertFile, sock.TLSKeyFile}
+
+	if auth := sock.AuthHTTP; auth != nil {
+		for _, ref := range []socket.SecretRef{auth.Password, auth.Token, auth.ClientSecret} {
+			if ref.Env != "" && (cfg.RemoteEnvPrefix == "" || !strings.HasPrefix(ref.Env, cfg.RemoteEnvPrefix)) {
+				return fmt.Errorf("environment variable %s cannot be referenced by sockets loaded from the remote API, see -remoteEnvPrefix", ref.Env)
+			}
+			files = append(files, ref.File)
+		}
+	}
+
+	for _, file := range files {
+		if file != "" && !withinDir(cfg.RemoteFileDir, file) {
+			return fmt.Errorf("file %s cannot be referenced by sockets loaded from the remote API, see -remoteFileDir", file)
+		}
+	}
+
+	return nil
+}
+
+// withinDir reports whether the file at the given path is located within dir. Symbolic links are resolved, so that
+// a link within dir cannot point outside of it. False is returned if dir is empty or either path cannot be resolved.
+func withinDir(dir, path string) bool {
+	if dir == "" {
+		return false
+	}
+
+	dir, err := resolvePath(dir)
+	if err != nil {
+		return false
+	}
+
+	if path, err = resolvePath(path); err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(dir, path)
+
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolvePath returns the absolute path of the given file with all symbolic links resolved.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.EvalSymlinks(abs)
+}
@@ -0,0 +1,131 @@
+package netrunner
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"go.vxn.dev/dish/pkg/config"
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+func TestCheckRemoteReferences(t *testing.T) {
+	dir := t.TempDir()
+
+	allowedFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(allowedFile, []byte("t0ken"), 0o600); err != nil {
+		t.Fatalf("failed to write secret file: %v", err)
+	}
+
+	outsideFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(outsideFile, []byte("t0ken"), 0o600); err != nil {
+		t.Fatalf("failed to write secret file: %v", err)
+	}
+
+	// A link within the allowed directory pointing outside of it.
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(outsideFile, link); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	cfg := &config.Config{RemoteEnvPrefix: "DISH_REMOTE_", RemoteFileDir: dir}
+
+	bearer := func(ref socket.SecretRef) *socket.HTTPAuth {
+		return &socket.HTTPAuth{Type: "bearer", Token: ref}
+	}
+
+	tests := []struct {
+		name    string
+		cfg     *config.Config
+		sock    socket.Socket
+		wantErr bool
+	}{
+		{name: "no references", cfg: &config.Config{}, sock: socket.Socket{}},
+		{name: "environment variable with the allowed prefix", cfg: cfg, sock: socket.Socket{AuthHTTP: bearer(socket.SecretRef{Env: "DISH_REMOTE_TOKEN"})}},
+		{name: "environment variable without the allowed prefix", cfg: cfg, sock: socket.Socket{AuthHTTP: bearer(socket.SecretRef{Env: "AWS_SECRET_ACCESS_KEY"})}, wantErr: true},
+		{name: "environment variable without a prefix allowed", cfg: &config.Config{}, sock: socket.Socket{AuthHTTP: bearer(socket.SecretRef{Env: "DISH_REMOTE_TOKEN"})}, wantErr: true},
+		{name: "secret file within the allowed directory", cfg: cfg, sock: socket.Socket{AuthHTTP: bearer(socket.SecretRef{File: allowedFile})}},
+		{name: "secret file outside of the allowed directory", cfg: cfg, sock: socket.Socket{AuthHTTP: bearer(socket.SecretRef{File: outsideFile})}, wantErr: true},
+		{name: "secret file without a directory allowed", cfg: &config.Config{}, sock: socket.Socket{AuthHTTP: bearer(socket.SecretRef{File: allowedFile})}, wantErr: true},
+		{name: "secret file escaping the allowed directory", cfg: cfg, sock: socket.Socket{AuthHTTP: bearer(socket.SecretRef{File: filepath.Join(dir, "..", filepath.Base(filepath.Dir(outsideFile)), "token")})}, wantErr: true},
+		{name: "symlink pointing outside of the allowed directory", cfg: cfg, sock: socket.Socket{AuthHTTP: bearer(socket.SecretRef{File: link})}, wantErr: true},
+		{name: "body file within the allowed directory", cfg: cfg, sock: socket.Socket{BodyFileHTTP: allowedFile}},
+		{name: "body file outside of the allowed directory", cfg: cfg, sock: socket.Socket{BodyFileHTTP: "/etc/passwd"}, wantErr: true},
+		{name: "TLS key outside of the allowed directory", cfg: cfg, sock: socket.Socket{TLSCertFile: allowedFile, TLSKeyFile: outsideFile}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkRemoteReferences(tt.sock, tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("checkRemoteReferences() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunSocket_RemoteReferences(t *testing.T) {
+	var requests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	t.Setenv("DISH_TEST_TOKEN", "t0ken")
+
+	sock := newTestServerSocket(t, server)
+	sock.AuthHTTP = &socket.HTTPAuth{Type: "bearer", Token: socket.SecretRef{Env: "DISH_TEST_TOKEN"}}
+
+	tests := []struct {
+		name      string
+		cfg       *config.Config
+		wantPass  bool
+		wantError string
+	}{
+		{
+			name:     "socket list loaded from a file",
+			cfg:      &config.Config{TimeoutSeconds: 5, Source: "sockets.json"},
+			wantPass: true,
+		},
+		{
+			name:      "socket list loaded from the remote API",
+			cfg:       &config.Config{TimeoutSeconds: 5, Source: "https://api.example.com/sockets"},
+			wantError: "environment variable DISH_TEST_TOKEN cannot be referenced",
+		},
+		{
+			name:     "socket list loaded from the remote API with the prefix allowed",
+			cfg:      &config.Config{TimeoutSeconds: 5, Source: "https://api.example.com/sockets", RemoteEnvPrefix: "DISH_TEST_"},
+			wantPass: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+
+			got, err := RunSocket(context.Background(), sock, tt.cfg, &MockLogger{})
+			if err != nil {
+				t.Fatalf("RunSocket(): unexpected error: %v", err)
+			}
+
+			if got.Passed != tt.wantPass {
+				t.Errorf("RunSocket(): expected passed = %v, got %v (error: %v)", tt.wantPass, got.Passed, got.Error)
+			}
+
+			if tt.wantError != "" {
+				if got.Error == nil || !strings.Contains(got.Error.Error(), tt.wantError) {
+					t.Errorf("RunSocket(): expected an error containing %q, got %v", tt.wantError, got.Error)
+				}
+
+				if n := requests.Load(); n != 0 {
+					t.Errorf("expected no request to be made, got %d", n)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package netrunner
+
+import (
+	"context"
+	"time"
+
+	"go.vxn.dev/dish/pkg/config"
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+// retryPolicy describes how many times and how often a socket test is attempted before the socket is reported as failed.
+type retryPolicy struct {
+	// Maximum number of attempts, including the first one.
+	attempts int
+
+	// Time to wait before the first retry. It is doubled for each further retry.
+	backoff time.Duration
+
+	// Timeout of a single attempt.
+	timeout time.Duration
+
+	// If true, all attempts and the backoff between them have to fit within a single timeout.
+	withinTimeout bool
+}
+
+// newRetryPolicy returns the retry policy for the given socket. The retry and timeout settings of the socket take
+// precedence over the global settings in the config. The number of retries, the backoff and whether the attempts have
+// to fit within a single timeout are overridden whenever set on the socket, even to zero values.
+func newRetryPolicy(sock socket.Socket, cfg *config.Config) retryPolicy {
+	policy := retryPolicy{
+		attempts:      int(cfg.Retries) + 1,
+		backoff:       time.Duration(cfg.RetryBackoffMs) * time.Millisecond,
+		timeout:       time.Duration(cfg.TimeoutSeconds) * time.Second,
+		withinTimeout: cfg.RetryWithinTimeout,
+	}
+
+	if sock.Retries != nil {
+		policy.attempts = max(*sock.Retries, 0) + 1
+	}
+
+	if sock.RetryWithinTimeout != nil {
+		policy.withinTimeout = *sock.RetryWithinTimeout
+	}
+
+	if sock.TimeoutMs > 0 {
+		policy.timeout = time.Duration(sock.TimeoutMs) * time.Millisecond
+	}
+
+	if sock.RetryBackoffMs != nil {
+		policy.backoff = time.Duration(max(*sock.RetryBackoffMs, 0)) * time.Millisecond
+	}
+
+	return policy
+}
+
+// runAttempts runs the test for the given socket until it passes or the attempts of the retry policy run out,
+// waiting for the backoff between the attempts. Each attempt is limited by the timeout of the policy. If the
+// attempts have to fit within the timeout, no more attempts are made once it passes. The result of the last
+// attempt is returned with the number of attempts made.
+func runAttempts(ctx context.Context, runner NetRunner, sock socket.Socket, policy retryPolicy) socket.Result {
+	if policy.withinTimeout {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.timeout)
+		defer cancel()
+	}
+
+	backoff := policy.backoff
+
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, policy.timeout)
+		result := runTest(attemptCtx, runner, sock)
+		cancel()
+
+		result.Attempts = attempt
+
+		if result.Passed || attempt >= policy.attempts || !sleepContext(ctx, backoff) {
+			return result
+		}
+
+		backoff *= 2
+	}
+}
+
+// sleepContext pauses the current goroutine for the given duration or until the context is done. It returns false
+// if the context was done first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
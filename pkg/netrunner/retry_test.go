@@ -0,0 +1,154 @@
+package netrunner
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.vxn.dev/dish/pkg/config"
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+// flakyRunner is a NetRunner which fails until it has been run the given number of times.
+type flakyRunner struct {
+	failures int32
+	runs     atomic.Int32
+}
+
+func (r *flakyRunner) RunTest(_ context.Context, sock socket.Socket) socket.Result {
+	if r.runs.Add(1) <= r.failures {
+		return socket.Result{Socket: sock, Error: errors.New("connection refused")}
+	}
+
+	return socket.Result{Socket: sock, Passed: true}
+}
+
+func TestNewRetryPolicy(t *testing.T) {
+	cfg := &config.Config{TimeoutSeconds: 10, Retries: 2, RetryBackoffMs: 500}
+
+	tests := []struct {
+		name   string
+		global *config.Config
+		sock   socket.Socket
+		want   retryPolicy
+	}{
+		{
+			name: "uses the global settings",
+			want: retryPolicy{attempts: 3, backoff: 500 * time.Millisecond, timeout: 10 * time.Second},
+		},
+		{
+			name: "prefers the socket settings",
+			sock: socket.Socket{Retries: ptr(4), RetryBackoffMs: ptr(100), RetryWithinTimeout: ptr(true), TimeoutMs: 1500},
+			want: retryPolicy{attempts: 5, backoff: 100 * time.Millisecond, timeout: 1500 * time.Millisecond, withinTimeout: true},
+		},
+		{
+			name: "disables retries set globally",
+			sock: socket.Socket{Retries: ptr(0)},
+			want: retryPolicy{attempts: 1, backoff: 500 * time.Millisecond, timeout: 10 * time.Second},
+		},
+		{
+			name: "disables the backoff set globally",
+			sock: socket.Socket{RetryBackoffMs: ptr(0)},
+			want: retryPolicy{attempts: 3, timeout: 10 * time.Second},
+		},
+		{
+			name:   "disables retrying within the timeout set globally",
+			global: &config.Config{TimeoutSeconds: 10, Retries: 2, RetryBackoffMs: 500, RetryWithinTimeout: true},
+			sock:   socket.Socket{RetryWithinTimeout: ptr(false)},
+			want:   retryPolicy{attempts: 3, backoff: 500 * time.Millisecond, timeout: 10 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := cfg
+			if tt.global != nil {
+				cfg = tt.global
+			}
+
+			if got := newRetryPolicy(tt.sock, cfg); got != tt.want {
+				t.Errorf("newRetryPolicy() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunAttempts(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int32
+		policy       retryPolicy
+		wantPassed   bool
+		wantAttempts int
+		minDuration  time.Duration
+	}{
+		{
+			name:         "does not retry a passing test",
+			policy:       retryPolicy{attempts: 3, timeout: time.Second},
+			wantPassed:   true,
+			wantAttempts: 1,
+		},
+		{
+			name:         "does not retry without retries",
+			failures:     1,
+			policy:       retryPolicy{attempts: 1, timeout: time.Second},
+			wantAttempts: 1,
+		},
+		{
+			name:         "passes after retries with a doubling backoff",
+			failures:     2,
+			policy:       retryPolicy{attempts: 3, backoff: 20 * time.Millisecond, timeout: time.Second},
+			wantPassed:   true,
+			wantAttempts: 3,
+			minDuration:  60 * time.Millisecond,
+		},
+		{
+			name:         "fails when the attempts run out",
+			failures:     5,
+			policy:       retryPolicy{attempts: 3, timeout: time.Second},
+			wantAttempts: 3,
+		},
+		{
+			name:         "stops retrying when the attempts do not fit within the timeout",
+			failures:     5,
+			policy:       retryPolicy{attempts: 5, backoff: 30 * time.Millisecond, timeout: 50 * time.Millisecond, withinTimeout: true},
+			wantAttempts: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &flakyRunner{failures: tt.failures}
+
+			start := time.Now()
+			got := runAttempts(context.Background(), runner, socket.Socket{ID: "flaky"}, tt.policy)
+
+			if got.Passed != tt.wantPassed {
+				t.Errorf("runAttempts(): passed = %v, want %v (error: %v)", got.Passed, tt.wantPassed, got.Error)
+			}
+
+			if got.Attempts != tt.wantAttempts {
+				t.Errorf("runAttempts(): attempts = %d, want %d", got.Attempts, tt.wantAttempts)
+			}
+
+			if elapsed := time.Since(start); elapsed < tt.minDuration {
+				t.Errorf("runAttempts(): took %v, expected at least %v of backoff", elapsed, tt.minDuration)
+			}
+		})
+	}
+}
+
+func TestSleepContext(t *testing.T) {
+	if !sleepContext(context.Background(), time.Millisecond) {
+		t.Error("sleepContext(): expected true when the duration elapses")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if sleepContext(ctx, time.Minute) {
+		t.Error("sleepContext(): expected false when the context is done")
+	}
+}
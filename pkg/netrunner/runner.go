@@ -1,16 +1,22 @@
 // Package netrunner provides functionality for checking the availability of sockets and/or endpoints.
-// It provides tcpRunner, httpRunner and icmpRunner structs implementing the NetRunner interface, which can be used to
-// run checks on the provided targets.
+// It provides tcpRunner, udpRunner, httpRunner, transactionRunner, icmpRunner and dnsRunner structs implementing the NetRunner interface, which can be used to
+// run checks on the provided targets. Additional runners for custom check types can be made available using Register.
 package netrunner
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"net/url"
+	"os"
 	"regexp"
 	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -21,74 +27,275 @@ import (
 
 const agentVersion = "1.12"
 
+// maxExpectBytes is the maximum number of bytes read from a TCP connection while waiting for the expected reply.
+const maxExpectBytes = 64 << 10
+
+// allowedHTTPMethods lists the HTTP methods which can be used for HTTP socket checks.
+var allowedHTTPMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
 // RunSocketTest is intended to be invoked in a separate goroutine.
-// It runs a test for the given socket and sends the result through the given channel.
+// It runs a test for the given socket using RunSocket and sends the result through the given channel.
 // If the test fails to start, the error is logged to STDOUT and no result is
 // sent. On return, Done() is called on the WaitGroup and the channel is closed.
 func RunSocketTest(sock socket.Socket, out chan<- socket.Result, wg *sync.WaitGroup, cfg *config.Config, logger logger.Logger) {
 	defer wg.Done()
 	defer close(out)
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.TimeoutSeconds)*time.Second)
-	defer cancel()
-
-	runner, err := NewNetRunner(sock, logger)
+	result, err := RunSocket(context.Background(), sock, cfg, logger)
 	if err != nil {
 		logger.Errorf("failed to test socket: %v", err.Error())
 		return
 	}
 
-	out <- runner.RunTest(ctx, sock)
+	out <- result
+}
+
+// RunSocket runs a test for the given socket, retrying it according to the socket and global retry settings,
+// and returns its result. Each attempt is limited by the timeout set on the socket, or the global timeout if
+// not set, and all attempts are cancelled once the given context is done. A non-nil error is returned if the
+// test fails to start, e.g. if no runner is available for the socket. A socket loaded from the remote API which
+// references environment variables or files not allowed by the config is reported as failed without being tested.
+func RunSocket(ctx context.Context, sock socket.Socket, cfg *config.Config, logger logger.Logger) (socket.Result, error) {
+	if sock.ICMPAddressFamily == "" {
+		sock.ICMPAddressFamily = cfg.ICMPAddressFamily
+	}
+
+	// The global proxy does not apply to sockets which connect to a specific address, as the connection
+	// would be made by the proxy instead.
+	if sock.Proxy == "" && sock.ConnectTo == "" && !sock.AllAddresses {
+		sock.Proxy = cfg.Proxy
+	}
+
+	if !socket.IsFilePath(cfg.Source) {
+		if err := checkRemoteReferences(sock, cfg); err != nil {
+			return socket.Result{Socket: sock, Error: err}, nil
+		}
+	}
+
+	runner, err := NewNetRunner(sock, logger)
+	if err != nil {
+		return socket.Result{}, err
+	}
+
+	return runAttempts(ctx, runner, sock, newRetryPolicy(sock, cfg)), nil
+}
+
+// runTest runs the test for the given socket using the provided runner. If all addresses of the socket are
+// to be checked, the test is run against each of them.
+func runTest(ctx context.Context, runner NetRunner, sock socket.Socket) socket.Result {
+	if sock.AllAddresses {
+		return runAllAddresses(ctx, runner, sock)
+	}
+
+	return runTimed(ctx, runner, sock)
+}
+
+// runTimed runs the test for the given socket using the provided runner and records its duration. A test which
+// passes but exceeds the maximum response time set on the socket is marked as failed. If the runner has to be
+// prepared for the test, it is done before the duration starts to be measured.
+func runTimed(ctx context.Context, runner NetRunner, sock socket.Socket) socket.Result {
+	if p, ok := runner.(preparer); ok {
+		if err := p.prepare(ctx, sock); err != nil {
+			return socket.Result{Socket: sock, Error: err}
+		}
+	}
+
+	start := time.Now()
+	result := runner.RunTest(ctx, sock)
+	result.Duration = time.Since(start)
+
+	limit := time.Duration(sock.MaxResponseTimeMs) * time.Millisecond
+	if result.Passed && limit > 0 && result.Duration > limit {
+		result.Passed = false
+		result.Error = fmt.Errorf("response time %dms exceeded the limit of %dms", result.Duration.Milliseconds(), limit.Milliseconds())
+	}
+
+	return result
 }
 
-// NetRunner is used to run tests for a socket.
+// runAllAddresses resolves the host the socket connects to and runs the test against each of its addresses concurrently. ICMP
+// tests are only run against the addresses of the address family set on the socket, if any. The result passes
+// only if the tests against all addresses pass, and holds the result for each address in AddressResults.
+func runAllAddresses(ctx context.Context, runner NetRunner, sock socket.Socket) socket.Result {
+	start := time.Now()
+
+	addrs, err := lookupIPAddr(ctx, connectHost(sock))
+	if err != nil {
+		return socket.Result{Socket: sock, Error: fmt.Errorf("failed to resolve socket host: %w", err), Duration: time.Since(start)}
+	}
+
+	if _, ok := runner.(*icmpRunner); ok && sock.ICMPAddressFamily != "" {
+		addrs = slices.DeleteFunc(addrs, func(addr net.IPAddr) bool {
+			_, err := selectICMPAddr([]net.IPAddr{addr}, sock.ICMPAddressFamily)
+			return err != nil
+		})
+	}
+
+	if len(addrs) == 0 {
+		return socket.Result{Socket: sock, Error: errors.New("host did not resolve to any address"), Duration: time.Since(start)}
+	}
+
+	results := make([]socket.Result, len(addrs))
+
+	var wg sync.WaitGroup
+	for i, addr := range addrs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			results[i] = runTimed(withTargetIP(ctx, addr.IP), runner, sock)
+			results[i].Address = addr.IP.String()
+		}()
+	}
+	wg.Wait()
+
+	var failed []string
+	for _, result := range results {
+		if !result.Passed {
+			failed = append(failed, fmt.Sprintf("%s: %v", result.Address, result.Error))
+		}
+	}
+
+	result := socket.Result{Socket: sock, Passed: len(failed) == 0, AddressResults: results, Duration: time.Since(start)}
+	if len(failed) > 0 {
+		result.Error = fmt.Errorf("%d of %d addresses failed: %s", len(failed), len(results), strings.Join(failed, "; "))
+	}
+
+	return result
+}
+
+// NetRunner is used to run tests for a socket. If all addresses of a socket are checked, RunTest is called
+// concurrently for each of them, with the address set on the context.
 type NetRunner interface {
 	RunTest(ctx context.Context, sock socket.Socket) socket.Result
 }
 
+// preparer is implemented by runners which have work to do before a test that does not count toward its duration,
+// e.g. obtaining an OAuth2 token shared with other sockets.
+type preparer interface {
+	prepare(ctx context.Context, sock socket.Socket) error
+}
+
+// Supported values of socket.Protocol.
+const (
+	ProtocolHTTP            = "http"
+	ProtocolHTTPTransaction = "http_transaction"
+	ProtocolTCP             = "tcp"
+	ProtocolUDP             = "udp"
+	ProtocolICMP            = "icmp"
+	ProtocolDNS             = "dns"
+)
+
+// httpURLRegex matches hosts which are HTTP or HTTPS URLs.
+var httpURLRegex = regexp.MustCompile("^(http|https)://")
+
+func init() {
+	Register(ProtocolHTTP, newHTTPRunner)
+	Register(ProtocolHTTPTransaction, newTransactionRunner)
+	Register(ProtocolTCP, newTCPRunner)
+	Register(ProtocolUDP, newUDPRunner)
+	Register(ProtocolICMP, newICMPRunner)
+	Register(ProtocolDNS, newDNSRunner)
+}
+
 // NewNetRunner determines the protocol used for the socket test and creates a
-// new NetRunner for it.
+// new NetRunner for it using the factory registered for the protocol.
 //
-// Rules for the test method determination (first matching rule applies):
+// If socket.Protocol is set, it takes precedence and the corresponding runner is
+// returned. A non-nil error is returned if no runner is registered for the
+// protocol or the socket is not valid for it. Otherwise, the protocol is
+// determined by the following rules (first matching rule applies):
 //   - If socket.Host starts with 'http://' or 'https://', a HTTP runner is returned.
 //   - If socket.Port is between 1 and 65535, a TCP runner is returned.
 //   - If socket.Host is not empty, an ICMP runner is returned.
 //   - If none of the above conditions are met, a non-nil error is returned.
 func NewNetRunner(sock socket.Socket, logger logger.Logger) (NetRunner, error) {
-	exp, err := regexp.Compile("^(http|https)://")
-	if err != nil {
-		return nil, fmt.Errorf("regex compilation failed: %w", err)
+	protocol := sock.Protocol
+	if protocol == "" {
+		var err error
+		if protocol, err = detectProtocol(sock); err != nil {
+			return nil, err
+		}
+	}
+
+	factory, ok := lookupFactory(protocol)
+	if !ok {
+		return nil, fmt.Errorf("unsupported protocol %q for the socket %s", sock.Protocol, sock.ID)
 	}
 
-	if exp.MatchString(sock.Host) {
-		return &httpRunner{client: &http.Client{}, logger: logger}, nil
+	return factory(sock, logger)
+}
+
+// detectProtocol determines the protocol of the given socket from its host and port.
+func detectProtocol(sock socket.Socket) (string, error) {
+	if httpURLRegex.MatchString(sock.Host) {
+		return ProtocolHTTP, nil
 	}
 
 	if sock.Port >= 1 && sock.Port <= 65535 {
-		return &tcpRunner{logger: logger}, nil
+		return ProtocolTCP, nil
 	}
 
 	if sock.Host != "" {
-		return &icmpRunner{logger: logger}, nil
+		return ProtocolICMP, nil
 	}
 
-	return nil, fmt.Errorf("no protocol could be determined from the socket %s", sock.ID)
+	return "", fmt.Errorf("no protocol could be determined from the socket %s", sock.ID)
+}
+
+// hostname returns the bare host name of the given socket host. If the host is a URL
+// (e.g. https://example.com/path), only its host name is returned.
+func hostname(host string) string {
+	if !strings.Contains(host, "://") {
+		return host
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return host
+	}
+
+	return u.Hostname()
 }
 
 type tcpRunner struct {
 	logger logger.Logger
 }
 
+// newTCPRunner returns a new tcpRunner. A non-nil error is returned if the socket port is not valid.
+func newTCPRunner(sock socket.Socket, logger logger.Logger) (NetRunner, error) {
+	if sock.Port < 1 || sock.Port > 65535 {
+		return nil, fmt.Errorf("protocol %s requires a port between 1 and 65535 for the socket %s", ProtocolTCP, sock.ID)
+	}
+
+	if err := validateCipherSuites(sock.TLSCipherSuites); err != nil {
+		return nil, fmt.Errorf("invalid tls_cipher_suites for the socket %s: %w", sock.ID, err)
+	}
+
+	return &tcpRunner{logger: logger}, nil
+}
+
 // RunTest is used to test TCP sockets. It opens a TCP connection with the given socket.
-// The test passes if the connection is successfully opened with no errors.
+// The test passes if the connection is successfully opened with no errors. If TLS is enabled
+// or a certificate expiry threshold is set, a TLS handshake is performed over the connection
+// and the test only passes if the handshake succeeds with the allowed version and cipher
+// suites and the presented certificate does not expire within the threshold. If a payload
+// to send or an expected reply is set, the payload is written to the connection and the test
+// only passes if the expected reply is read before the context is done.
 func (runner *tcpRunner) RunTest(ctx context.Context, sock socket.Socket) socket.Result {
-	endpoint := net.JoinHostPort(sock.Host, strconv.Itoa(sock.Port))
+	endpoint := net.JoinHostPort(hostname(sock.Host), strconv.Itoa(sock.Port))
 
 	runner.logger.Debug("TCP runner: connect: " + endpoint)
 
-	d := net.Dialer{}
-
-	conn, err := d.DialContext(ctx, "tcp", endpoint)
+	conn, err := dialContext(ctx, "tcp", connectAddress(sock, endpoint))
 	if err != nil {
 		return socket.Result{Socket: sock, Error: err, Passed: false}
 	}
@@ -102,47 +309,340 @@ func (runner *tcpRunner) RunTest(ctx context.Context, sock socket.Socket) socket
 		}
 	}()
 
-	return socket.Result{Socket: sock, Passed: true}
+	var (
+		rw   net.Conn = conn
+		cert *socket.Certificate
+	)
+
+	if sock.TLS || sock.CertExpiryWarnDays > 0 {
+		runner.logger.Debug("TCP runner: TLS handshake: " + endpoint)
+
+		tlsConn, err := tlsHandshake(ctx, conn, sock)
+		if err != nil {
+			return socket.Result{Socket: sock, Error: err, Passed: false}
+		}
+		rw = tlsConn
+
+		if sock.CertExpiryWarnDays > 0 {
+			state := tlsConn.ConnectionState()
+			if cert, err = checkCertificate(&state, sock.CertExpiryWarnDays); err != nil {
+				return socket.Result{Socket: sock, Error: err, Passed: false, Certificate: cert}
+			}
+		}
+	}
+
+	if sock.TCPSend != "" || sock.TCPExpect != "" || sock.TCPExpectRegex != "" {
+		runner.logger.Debug("TCP runner: send/expect: " + endpoint)
+
+		if err := exchange(ctx, rw, sock); err != nil {
+			return socket.Result{Socket: sock, Error: err, Passed: false, Certificate: cert}
+		}
+	}
+
+	return socket.Result{Socket: sock, Passed: true, Certificate: cert}
+}
+
+// exchange writes the payload configured for the given socket to the connection, if any, and reads from it
+// until the expected string and regular expression are matched. A non-nil error is returned if the connection
+// is closed, the context is done or maxExpectBytes are read before the expectations are matched.
+func exchange(ctx context.Context, conn net.Conn, sock socket.Socket) error {
+	// Unblock any pending reads and writes once the context is done.
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	if sock.TCPSend != "" {
+		if _, err := io.WriteString(conn, sock.TCPSend); err != nil {
+			return fmt.Errorf("failed to send payload: %w", err)
+		}
+	}
+
+	if sock.TCPExpect == "" && sock.TCPExpectRegex == "" {
+		return nil
+	}
+
+	var exp *regexp.Regexp
+	if sock.TCPExpectRegex != "" {
+		var err error
+		if exp, err = regexp.Compile(sock.TCPExpectRegex); err != nil {
+			return fmt.Errorf("invalid expect regex: %w", err)
+		}
+	}
+
+	matches := func(data []byte) bool {
+		return (sock.TCPExpect == "" || bytes.Contains(data, []byte(sock.TCPExpect))) &&
+			(exp == nil || exp.Match(data))
+	}
+
+	var (
+		data  []byte
+		chunk = make([]byte, 4096)
+	)
+
+	for len(data) < maxExpectBytes {
+		n, err := conn.Read(chunk)
+		data = append(data, chunk[:n]...)
+
+		if matches(data) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("expected reply not received (got %q): %w", truncate(data, 64), err)
+		}
+	}
+
+	return fmt.Errorf("expected reply not found in the first %d bytes received", maxExpectBytes)
 }
 
 type httpRunner struct {
 	client *http.Client
 	logger logger.Logger
+
+	// Client used to obtain OAuth2 tokens. It is only set if the socket uses OAuth2 auth.
+	tokenClient *http.Client
+}
+
+// newHTTPRunner returns a new httpRunner with a HTTP client configured for the given socket. A non-nil
+// error is returned if the socket host is not a HTTP URL or the client cannot be configured.
+func newHTTPRunner(sock socket.Socket, logger logger.Logger) (NetRunner, error) {
+	if !httpURLRegex.MatchString(sock.Host) {
+		return nil, fmt.Errorf("protocol %s requires the host of the socket %s to start with http:// or https://", ProtocolHTTP, sock.ID)
+	}
+
+	if len(sock.TLSCipherSuites) > 0 {
+		return nil, fmt.Errorf("tls_cipher_suites is only supported for %s checks, not for the socket %s", ProtocolTCP, sock.ID)
+	}
+
+	if sock.Proxy != "" && (sock.ConnectTo != "" || sock.AllAddresses) {
+		return nil, fmt.Errorf("a proxy cannot be combined with connect_to or all_addresses for the socket %s", sock.ID)
+	}
+
+	if err := validateRedirects(sock); err != nil {
+		return nil, fmt.Errorf("invalid redirect options for the socket %s: %w", sock.ID, err)
+	}
+
+	if sock.AuthHTTP != nil {
+		if err := validateHTTPAuth(sock); err != nil {
+			return nil, fmt.Errorf("invalid auth_http for the socket %s: %w", sock.ID, err)
+		}
+	}
+
+	client, err := newHTTPClient(sock)
+	if err != nil {
+		return nil, err
+	}
+
+	runner := &httpRunner{client: client, logger: logger}
+
+	if sock.AuthHTTP != nil && sock.AuthHTTP.Type == authOAuth2 {
+		// The token endpoint is requested through the proxy of the socket, if any, but without the TLS and
+		// connection options which only apply to the socket host.
+		runner.tokenClient, err = newHTTPClient(socket.Socket{ID: sock.ID, Proxy: sock.Proxy})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return runner, nil
+}
+
+// newICMPRunner returns a new icmpRunner. A non-nil error is returned if the socket host is empty or if the
+// socket has invalid ICMP options set.
+func newICMPRunner(sock socket.Socket, logger logger.Logger) (NetRunner, error) {
+	if sock.Host == "" {
+		return nil, fmt.Errorf("protocol %s requires a host for the socket %s", ProtocolICMP, sock.ID)
+	}
+
+	if !validICMPFamily(sock.ICMPAddressFamily) {
+		return nil, fmt.Errorf("unsupported icmp address family %q for the socket %s", sock.ICMPAddressFamily, sock.ID)
+	}
+
+	if sock.ICMPCount < 0 || sock.ICMPIntervalMs < 0 {
+		return nil, fmt.Errorf("icmp count and interval cannot be negative for the socket %s", sock.ID)
+	}
+
+	if sock.MaxPacketLossPercent < 0 || sock.MaxPacketLossPercent > 100 {
+		return nil, fmt.Errorf("maximum packet loss must be between 0 and 100 percent for the socket %s", sock.ID)
+	}
+
+	return &icmpRunner{logger: logger}, nil
+}
+
+// newHTTPClient returns a HTTP client for the given socket. If any TLS options are set on the socket,
+// the client uses a transport with the corresponding TLS configuration. If the socket has a host to
+// connect to set, the transport connects to it while the request keeps the socket host for the Host
+// header and SNI. If all addresses of the socket are checked, the transport connects to the target IP
+// address set on the request context and does not reuse connections, so that each address is checked
+// over a new connection. Both only apply to connections to the socket host and port, so that e.g. redirects
+// to other hosts are followed as usual. If a proxy is set on the socket, the transport makes requests through
+// it. The client follows redirects according to the redirect options of the socket.
+func newHTTPClient(sock socket.Socket) (*http.Client, error) {
+	client := &http.Client{CheckRedirect: redirectPolicy(sock)}
+
+	if !hasTLSOptions(sock) && !sock.AllAddresses && sock.ConnectTo == "" && sock.Proxy == "" {
+		return client, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if hasTLSOptions(sock) {
+		config, err := tlsConfig(sock)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = config
+	}
+
+	if sock.AllAddresses || sock.ConnectTo != "" {
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialSocket(ctx, sock, network, addr)
+		}
+		// A proxy set in the environment would make the connection instead, bypassing the address of the socket.
+		transport.Proxy = nil
+	}
+
+	if sock.AllAddresses {
+		transport.DisableKeepAlives = true
+	}
+
+	if sock.Proxy != "" {
+		proxyURL, err := config.ParseProxyURL(sock.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy for the socket %s: %w", sock.ID, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	client.Transport = transport
+
+	return client, nil
 }
 
-// RunTest is used to test HTTP/S endpoints exclusively. It executes a HTTP GET
-// request to the given socket. The test passes if the request did not end with
-// an error and the response status matches the expected HTTP codes.
+// RunTest is used to test HTTP/S endpoints exclusively. It executes a HTTP
+// request to the given socket using the configured method (GET by default),
+// headers, body and authentication. The test passes if the request did not
+// end with an error, the response status matches the expected HTTP codes, the
+// response headers and body satisfy the configured assertions and the TLS
+// certificate of the socket host does not expire within the configured number
+// of days, if any. The certificate is checked even if redirects to another
+// host were followed.
 func (runner *httpRunner) RunTest(ctx context.Context, sock socket.Socket) socket.Result {
-	url := sock.Host + ":" + strconv.Itoa(sock.Port) + sock.PathHTTP
+	target := sock.Host + ":" + strconv.Itoa(sock.Port) + sock.PathHTTP
 
-	runner.logger.Debug("HTTP runner: connect:", url)
+	runner.logger.Debug("HTTP runner: connect:", target)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	req, err := newHTTPRequest(ctx, sock, target)
 	if err != nil {
 		return socket.Result{Socket: sock, Passed: false, Error: err}
 	}
-	req.Header.Set("User-Agent", fmt.Sprintf("dish/%s", agentVersion))
 
-	resp, err := runner.client.Do(req)
+	token, err := runner.authorize(ctx, req, sock.AuthHTTP)
 	if err != nil {
 		return socket.Result{Socket: sock, Passed: false, Error: err}
 	}
 
+	// The request is only traced once authorized, so that obtaining a token does not count toward its timings.
+	tracer := &httpTracer{}
+	req = req.WithContext(tracer.withTrace(ctx))
+
+	resp, err := runner.client.Do(req)
+	if err != nil {
+		return socket.Result{Socket: sock, Passed: false, Error: err, HTTPTimings: tracer.timings()}
+	}
+
+	// A rejected OAuth2 token is dropped from the cache so that a retry or another socket obtains a new one.
+	if token != "" && resp.StatusCode == http.StatusUnauthorized {
+		oauth2Tokens.invalidate(sock.AuthHTTP, token)
+	}
+
 	defer func() {
 		if cerr := resp.Body.Close(); cerr != nil {
 			runner.logger.Errorf("failed to close body for %v", cerr)
 		}
 	}()
 
-	if !slices.Contains(sock.ExpectedHTTPCodes, resp.StatusCode) {
-		err = fmt.Errorf("expected codes: %v, got %d", sock.ExpectedHTTPCodes, resp.StatusCode)
+	result := socket.Result{Socket: sock, ResponseCode: resp.StatusCode, HTTPTimings: tracer.timings()}
+
+	if sock.CertExpiryWarnDays > 0 {
+		result.Certificate, result.Error = checkCertificate(firstResponse(resp).TLS, sock.CertExpiryWarnDays)
+		if result.Error != nil {
+			return result
+		}
 	}
 
-	return socket.Result{
-		Socket:       sock,
-		Passed:       slices.Contains(sock.ExpectedHTTPCodes, resp.StatusCode),
-		ResponseCode: resp.StatusCode,
-		Error:        err,
+	result.Error = expectationsFromSocket(sock).check(resp)
+	result.Passed = result.Error == nil
+
+	return result
+}
+
+// firstResponse returns the response to the request made to the socket host itself, i.e. the first redirect
+// response if redirects were followed, or the given response otherwise.
+func firstResponse(resp *http.Response) *http.Response {
+	for resp.Request != nil && resp.Request.Response != nil {
+		resp = resp.Request.Response
 	}
+
+	return resp
+}
+
+// newHTTPRequest creates a new HTTP request for the given socket with the provided target URL. The method,
+// headers and body of the request are set according to the socket's configuration. If no method is
+// set, GET is used. The dish User-Agent header is set unless overridden by the socket's headers.
+func newHTTPRequest(ctx context.Context, sock socket.Socket, target string) (*http.Request, error) {
+	method := http.MethodGet
+	if sock.MethodHTTP != "" {
+		method = strings.ToUpper(sock.MethodHTTP)
+	}
+
+	if !slices.Contains(allowedHTTPMethods, method) {
+		return nil, fmt.Errorf("unsupported HTTP method: %s", sock.MethodHTTP)
+	}
+
+	body, err := httpRequestBody(sock)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", fmt.Sprintf("dish/%s", agentVersion))
+
+	for name, value := range sock.HeadersHTTP {
+		// The Host header is not sent from req.Header by net/http, it has to be set explicitly.
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+
+	return req, nil
+}
+
+// httpRequestBody returns a reader of the request body configured for the given socket,
+// either inline or loaded from a file. A nil reader is returned if no body is configured.
+func httpRequestBody(sock socket.Socket) (io.Reader, error) {
+	if sock.BodyHTTP != "" && sock.BodyFileHTTP != "" {
+		return nil, errors.New("only one of body_http and body_file_http can be set")
+	}
+
+	if sock.BodyFileHTTP != "" {
+		data, err := os.ReadFile(sock.BodyFileHTTP)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read HTTP request body file: %w", err)
+		}
+		return bytes.NewReader(data), nil
+	}
+
+	if sock.BodyHTTP != "" {
+		return strings.NewReader(sock.BodyHTTP), nil
+	}
+
+	return nil, nil
 }
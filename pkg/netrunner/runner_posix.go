@@ -22,39 +22,77 @@ type ICMPType int
 const (
 	echoReply   ICMPType = 0
 	echoRequest ICMPType = 8
+
+	echoRequestV6 ICMPType = 128
+	echoReplyV6   ICMPType = 129
 )
 
 const (
 	ipStripHdr = 23
 	testID     = 0x1234
-	testSeq    = 0x0001
 )
 
 type icmpRunner struct {
 	logger logger.Logger
 }
 
-// RunTest is used to test ICMP sockets. It sends an ICMP Echo Request to the given socket using
-// non-privileged ICMP and verifies the reply. The test passes if the reply has the same payload
-// as the request. Returns an error if the socket host cannot be resolved to an IPv4 address. If
-// the host resolves to more than one address, only the first one is used.
+// errNoEchoReply is returned by receiveEchoReply if no reply to the echo request arrives before the deadline.
+var errNoEchoReply = errors.New("no echo reply received")
+
+// RunTest is used to test ICMP sockets. It sends ICMP Echo Requests (or ICMPv6 Echo Requests for IPv6 addresses)
+// to the given socket using non-privileged ICMP and verifies the replies. A reply is valid if it has the same payload
+// as its request. If the socket has a host to connect to set, it is pinged instead of the socket host. If the socket
+// has an address family set, the first resolved address of that family is used. Otherwise, the first resolved address
+// is used. Returns an error if no suitable address is found.
+//
+// By default, a single echo request is sent and the test passes if a valid reply arrives before the context is done.
+// If more requests are set on the socket, they are sent in the set interval and a reply which does not arrive within
+// the interval is counted as lost. The test then passes unless no reply arrives or the packet loss exceeds the limit
+// set on the socket. The packet loss and round-trip time statistics are returned in the result.
 func (runner *icmpRunner) RunTest(ctx context.Context, sock socket.Socket) socket.Result {
-	runner.logger.Debugf("Resolving host '%s' to an IP address", sock.Host)
+	host := connectHost(sock)
 
-	addr, err := net.DefaultResolver.LookupIPAddr(ctx, sock.Host)
+	runner.logger.Debugf("Resolving host '%s' to an IP address", host)
+
+	addrs, err := resolveIPAddrs(ctx, host)
 	if err != nil {
 		return socket.Result{Socket: sock, Error: fmt.Errorf("failed to resolve socket host: %w", err)}
 	}
 
-	ip := addr[0].IP.To4()
-	if ip == nil {
-		return socket.Result{Socket: sock, Error: errors.New("not a valid IPv4 address")}
+	addr, err := selectICMPAddr(addrs, sock.ICMPAddressFamily)
+	if err != nil {
+		return socket.Result{Socket: sock, Error: err}
 	}
 
-	sockAddr := &syscall.SockaddrInet4{Addr: [4]byte(ip)}
+	ip := addr.IP
+	isIPv6 := ip.To4() == nil
+
+	var (
+		domain    = syscall.AF_INET
+		proto     = syscall.IPPROTO_ICMP
+		reqType   = echoRequest
+		replyType = echoReply
+		sockAddr  syscall.Sockaddr
+	)
+
+	if isIPv6 {
+		domain, proto, reqType, replyType = syscall.AF_INET6, syscall.IPPROTO_ICMPV6, echoRequestV6, echoReplyV6
+
+		sa := &syscall.SockaddrInet6{Addr: [16]byte(ip.To16())}
+		if addr.Zone != "" {
+			iface, err := net.InterfaceByName(addr.Zone)
+			if err != nil {
+				return socket.Result{Socket: sock, Error: fmt.Errorf("failed to resolve the zone of the IPv6 address: %w", err)}
+			}
+			sa.ZoneId = uint32(iface.Index)
+		}
+		sockAddr = sa
+	} else {
+		sockAddr = &syscall.SockaddrInet4{Addr: [4]byte(ip.To4())}
+	}
 
 	// When using ICMP over DGRAM, Linux Kernel automatically sets (overwrites) and
-	// validates the id, seq and checksum of each incoming and outgoing ICMP message.
+	// validates the id and checksum of each incoming and outgoing ICMP message.
 	// This is largely non-documented in the linux man pages. The closest I found is:
 	// - (Linux news) lwn.net/Articles/420800/
 	// - (MacOS man) https://www.manpagez.com/man/4/icmp/
@@ -62,7 +100,7 @@ func (runner *icmpRunner) RunTest(ctx context.Context, sock socket.Socket) socke
 	// "[...] most Linux systems use a unique identifier for every ping process, and sequence
 	// number is an increasing number within that process. Windows uses a fixed identifier, which
 	// varies between Windows versions, and a sequence number that is only reset at boot time."
-	sysSocket, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, syscall.IPPROTO_ICMP)
+	sysSocket, err := syscall.Socket(domain, syscall.SOCK_DGRAM, proto)
 	if err != nil {
 		return socket.Result{Socket: sock, Error: fmt.Errorf("failed to create a non-privileged icmp socket: %w", err)}
 	}
@@ -76,70 +114,153 @@ func (runner *icmpRunner) RunTest(ctx context.Context, sock socket.Socket) socke
 		}
 	}()
 
-	if runtime.GOOS == "darwin" {
+	// IPv6 sockets never deliver the IP header, so it only needs to be stripped for IPv4.
+	if runtime.GOOS == "darwin" && !isIPv6 {
 		if err := syscall.SetsockoptInt(sysSocket, syscall.IPPROTO_IP, ipStripHdr, 1); err != nil {
 			return socket.Result{Socket: sock, Error: fmt.Errorf("failed to set ip strip header: %w", err)}
 		}
 	}
 
-	if d, ok := ctx.Deadline(); ok {
-		// Set a socket receive timeout.
-		t := syscall.NsecToTimeval(time.Until(d).Nanoseconds())
-		if err := syscall.SetsockoptTimeval(sysSocket, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &t); err != nil {
-			return socket.Result{Socket: sock, Error: fmt.Errorf("failed to set a timeout on a non-privileged icmp socket: %w", err)}
+	count := icmpCount(sock)
+	interval := icmpInterval(sock)
+	payload := []byte("ICMP echo")
+
+	// Maximum Transmission Unit (MTU) equals 1500 bytes.
+	// Recvfrom before writing to the buffer, checks its length (not capacity).
+	// If the length of the buffer is too small to fit the data then it's silently truncated.
+	replyBuf := make([]byte, 1500)
+
+	var (
+		sent int
+		rtts []time.Duration
+	)
+
+	for seq := 1; seq <= count; seq++ {
+		start := time.Now()
+
+		// A single echo request waits for its reply until the context is done, more requests wait for at most the interval.
+		deadline, _ := ctx.Deadline()
+		if count > 1 && (deadline.IsZero() || start.Add(interval).Before(deadline)) {
+			deadline = start.Add(interval)
+		}
+
+		reqBuf := newEchoRequest(reqType, uint16(seq), payload, isIPv6)
+
+		runner.logger.Debugf("ICMP runner: send to %s (seq %d)", ip, seq)
+
+		if err := syscall.Sendto(sysSocket, reqBuf, 0, sockAddr); err != nil {
+			return socket.Result{Socket: sock, Error: fmt.Errorf("failed to send an echo request: %w", err), ICMPStats: newICMPStats(sent, rtts)}
+		}
+		sent++
+
+		runner.logger.Debugf("ICMP runner: recv from %s (seq %d)", ip, seq)
+
+		err := receiveEchoReply(sysSocket, replyBuf, reqBuf, replyType, ip, deadline)
+		switch {
+		case errors.Is(err, errNoEchoReply):
+			runner.logger.Debugf("ICMP runner: no reply from %s (seq %d)", ip, seq)
+		case err != nil:
+			return socket.Result{Socket: sock, Error: err, ICMPStats: newICMPStats(sent, rtts)}
+		default:
+			rtts = append(rtts, time.Since(start))
+		}
+
+		// Wait for the rest of the interval before sending the next echo request.
+		if seq < count && !sleepContext(ctx, time.Until(start.Add(interval))) {
+			break
 		}
 	}
 
-	payload := []byte("ICMP echo")
+	stats := newICMPStats(sent, rtts)
+
+	runner.logger.Debugf(
+		"ICMP runner: %s: %d packets transmitted, %d received, %.1f%% packet loss, rtt min/avg/max/mdev = %v/%v/%v/%v",
+		ip, stats.Sent, stats.Received, stats.PacketLoss, stats.MinRTT, stats.AvgRTT, stats.MaxRTT, stats.MdevRTT,
+	)
+
+	if err := checkPacketLoss(stats, sock.MaxPacketLossPercent); err != nil {
+		return socket.Result{Socket: sock, Error: err, ICMPStats: stats}
+	}
 
+	return socket.Result{Socket: sock, Passed: true, ICMPStats: stats}
+}
+
+// newEchoRequest returns an echo request message of the given type with the given sequence number and payload.
+func newEchoRequest(reqType ICMPType, seq uint16, payload []byte, isIPv6 bool) []byte {
 	// ICMP Header size is 8 bytes.
-	reqBuf := make([]byte, 8+len(payload))
+	buf := make([]byte, 8+len(payload))
 
 	// ICMP Header.
-	// ID, Seq and Checksum are filled in automatically by the kernel on linux machines, not on darwin ipv4
-	reqBuf[0] = byte(echoRequest) // Type: Echo
-	copy(reqBuf[8:], payload)
+	// ID and Checksum are filled in automatically by the kernel on linux machines, not on darwin ipv4
+	buf[0] = byte(reqType) // Type: Echo
+	binary.BigEndian.PutUint16(buf[6:8], seq)
+	copy(buf[8:], payload)
 
-	// Set the ID, Seq and Checksum for the darwin based machines
+	// Set the ID and Checksum for the darwin based machines. The ICMPv6 checksum covers
+	// a pseudo-header including the source address and is always computed by the kernel.
 	if runtime.GOOS == "darwin" {
-		binary.BigEndian.PutUint16(reqBuf[4:6], testID)
-		binary.BigEndian.PutUint16(reqBuf[6:8], testSeq)
-		csum := checksum(reqBuf)
-		reqBuf[2] ^= byte(csum)
-		reqBuf[3] ^= byte(csum >> 8)
+		binary.BigEndian.PutUint16(buf[4:6], testID)
+		if !isIPv6 {
+			csum := checksum(buf)
+			buf[2] ^= byte(csum)
+			buf[3] ^= byte(csum >> 8)
+		}
 	}
 
-	runner.logger.Debug("ICMP runner: send to " + ip.String())
+	return buf
+}
 
-	if err := syscall.Sendto(sysSocket, reqBuf, 0, sockAddr); err != nil {
-		return socket.Result{Socket: sock, Error: fmt.Errorf("failed to send an echo request: %w", err)}
-	}
+// receiveEchoReply reads from the given socket into buf until a reply to the given echo request arrives from the
+// given IP address. Replies from other addresses, e.g. to concurrent checks of other hosts sharing the echo ID, and
+// replies with a different sequence number, e.g. late replies to previous requests, are skipped. If the deadline is
+// not zero, errNoEchoReply is returned when it passes. A non-nil error is also returned if the reply is not valid.
+func receiveEchoReply(fd int, buf, req []byte, replyType ICMPType, ip net.IP, deadline time.Time) error {
+	for {
+		if !deadline.IsZero() {
+			remaining := time.Until(deadline)
+			if remaining < time.Microsecond {
+				return errNoEchoReply
+			}
 
-	// Maximum Transmission Unit (MTU) equals 1500 bytes.
-	// Recvfrom before writing to the buffer, checks its length (not capacity).
-	// If the length of the buffer is too small to fit the data then it's silently truncated.
-	replyBuf := make([]byte, 1500)
+			// Set a socket receive timeout.
+			t := syscall.NsecToTimeval(remaining.Nanoseconds())
+			if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &t); err != nil {
+				return fmt.Errorf("failed to set a timeout on a non-privileged icmp socket: %w", err)
+			}
+		}
 
-	runner.logger.Debug("ICMP runner: recv from " + ip.String())
+		n, from, err := syscall.Recvfrom(fd, buf, 0)
+		switch {
+		case errors.Is(err, syscall.EINTR):
+			continue
+		case errors.Is(err, syscall.EAGAIN), errors.Is(err, syscall.EWOULDBLOCK):
+			return errNoEchoReply
+		case err != nil:
+			return fmt.Errorf("failed to receive a reply from a socket: %w", err)
+		}
 
-	n, _, err := syscall.Recvfrom(sysSocket, replyBuf, 0)
-	if err != nil {
-		return socket.Result{Socket: sock, Error: fmt.Errorf("failed to receive a reply from a socket: %w", err)}
-	}
+		if !isReplyFrom(from, ip) {
+			continue
+		}
 
-	if n < 8 {
-		return socket.Result{Socket: sock, Error: fmt.Errorf("reply is too short: received %d bytes ", n)}
-	}
+		if n < 8 {
+			return fmt.Errorf("reply is too short: received %d bytes ", n)
+		}
 
-	if replyBuf[0] != byte(echoReply) {
-		return socket.Result{Socket: sock, Error: errors.New("received unexpected reply type")}
-	}
+		if buf[0] != byte(replyType) {
+			return errors.New("received unexpected reply type")
+		}
 
-	if !bytes.Equal(reqBuf[8:], replyBuf[8:n]) {
-		return socket.Result{Socket: sock, Error: errors.New("failed to validate echo reply: payloads are not equal")}
-	}
+		if !bytes.Equal(req[6:8], buf[6:8]) {
+			continue
+		}
 
-	return socket.Result{Socket: sock, Passed: true}
+		if !bytes.Equal(req[8:], buf[8:n]) {
+			return errors.New("failed to validate echo reply: payloads are not equal")
+		}
+
+		return nil
+	}
 }
 
 // checksum calculates the internet checksum for the given byte slice.
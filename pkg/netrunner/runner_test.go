@@ -3,10 +3,17 @@ package netrunner
 import (
 	"context"
 	"flag"
+	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
 	"runtime"
 	"slices"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -257,8 +264,9 @@ func TestRunSocketTest(t *testing.T) {
 		}
 
 		want := socket.Result{
-			Socket: sock,
-			Passed: true,
+			Socket:   sock,
+			Passed:   true,
+			Attempts: 1,
 		}
 
 		c := make(chan socket.Result)
@@ -293,12 +301,68 @@ func TestRunSocketTest(t *testing.T) {
 			t.Error("RunSocketTest: the output channel has not been closed after returning")
 		}
 
-		if !cmp.Equal(got, want) {
+		if !cmp.Equal(got, want, cmpopts.IgnoreFields(socket.Result{}, "Duration")) {
 			t.Fatalf("RunSocketTest:\n want = %v\n got = %v\n", want, got)
 		}
 	})
 }
 
+// sleepRunner is a NetRunner which passes after sleeping for the given duration.
+type sleepRunner struct {
+	d time.Duration
+}
+
+func (r sleepRunner) RunTest(_ context.Context, sock socket.Socket) socket.Result {
+	time.Sleep(r.d)
+	return socket.Result{Socket: sock, Passed: true}
+}
+
+func TestRunTest_ResponseTime(t *testing.T) {
+	tests := []struct {
+		name       string
+		sleep      time.Duration
+		maxMs      int
+		wantPassed bool
+	}{
+		{
+			name:       "passes without a response time limit",
+			sleep:      20 * time.Millisecond,
+			wantPassed: true,
+		},
+		{
+			name:       "passes within the response time limit",
+			sleep:      time.Millisecond,
+			maxMs:      1000,
+			wantPassed: true,
+		},
+		{
+			name:       "fails over the response time limit",
+			sleep:      20 * time.Millisecond,
+			maxMs:      5,
+			wantPassed: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sock := socket.Socket{ID: "slow", MaxResponseTimeMs: tt.maxMs}
+
+			got := runTest(context.Background(), sleepRunner{d: tt.sleep}, sock)
+			if got.Passed != tt.wantPassed {
+				t.Fatalf("runTest(): passed = %v, want %v (error: %v)", got.Passed, tt.wantPassed, got.Error)
+			}
+
+			if !tt.wantPassed && got.Error == nil {
+				t.Fatal("runTest(): expected an error, got nil")
+			}
+
+			if got.Duration < tt.sleep {
+				t.Fatalf("runTest(): duration = %v, want at least %v", got.Duration, tt.sleep)
+			}
+		})
+	}
+}
+
 func TestNewNetRunner(t *testing.T) {
 	type args struct {
 		sock   socket.Socket
@@ -404,6 +468,100 @@ func TestNewNetRunner(t *testing.T) {
 	}
 }
 
+func TestNewNetRunner_ExplicitProtocol(t *testing.T) {
+	tests := []struct {
+		name    string
+		sock    socket.Socket
+		want    NetRunner
+		wantErr bool
+	}{
+		{
+			name: "returns an icmpRunner for an ICMP socket with a port set",
+			sock: socket.Socket{Host: "google.com", Port: 443, Protocol: "icmp"},
+			want: &icmpRunner{logger: &MockLogger{}},
+		},
+		{
+			name: "returns a tcpRunner for a TCP socket with an HTTP host",
+			sock: socket.Socket{Host: "http://google.com", Port: 80, Protocol: "TCP"},
+			want: &tcpRunner{logger: &MockLogger{}},
+		},
+		{
+			name: "returns an httpRunner for an HTTP socket",
+			sock: socket.Socket{Host: "https://google.com", Port: 443, Protocol: "http"},
+			want: &httpRunner{client: &http.Client{}, logger: &MockLogger{}},
+		},
+		{
+			name:    "returns an error for an HTTP socket without a URL host",
+			sock:    socket.Socket{Host: "google.com", Port: 443, Protocol: "http"},
+			wantErr: true,
+		},
+		{
+			name:    "returns an error for a TCP socket without a port",
+			sock:    socket.Socket{Host: "google.com", Protocol: "tcp"},
+			wantErr: true,
+		},
+		{
+			name:    "returns an error for an ICMP socket without a host",
+			sock:    socket.Socket{Port: 80, Protocol: "icmp"},
+			wantErr: true,
+		},
+		{
+			name:    "returns an error for an unsupported protocol",
+			sock:    socket.Socket{Host: "google.com", Port: 80, Protocol: "gopher"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewNetRunner(tt.sock, &MockLogger{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewNetRunner():\n error = %v\n wantErr = %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("NewNetRunner():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostname(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{host: "example.com", want: "example.com"},
+		{host: "192.168.0.1", want: "192.168.0.1"},
+		{host: "https://example.com", want: "example.com"},
+		{host: "http://example.com:8080/health", want: "example.com"},
+		{host: "https://[::1]", want: "::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := hostname(tt.host); got != tt.want {
+				t.Errorf("hostname(%q) = %q, want %q", tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTcpRunner_RunTest_URLHost(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start a TCP listener: %v", err)
+	}
+	defer ln.Close() //nolint:errcheck
+
+	sock := socket.Socket{Host: "http://127.0.0.1", Port: ln.Addr().(*net.TCPAddr).Port, Protocol: "tcp"}
+
+	runner := tcpRunner{logger: &MockLogger{}}
+	if got := runner.RunTest(context.Background(), sock); !got.Passed {
+		t.Fatalf("tcpRunner.RunTest(): expected the test to pass, got error: %v", got.Error)
+	}
+}
+
 // TestTcpRunner_RunTest is an integration test. It executes network calls to
 // external public servers.
 func TestTcpRunner_RunTest(t *testing.T) {
@@ -556,7 +714,7 @@ func TestHttpRunner_RunTest(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			got := tt.runner.RunTest(context.Background(), tt.args.sock)
-			if !cmp.Equal(got, tt.want, cmpopts.EquateErrors()) {
+			if !cmp.Equal(got, tt.want, cmpopts.EquateErrors(), cmpopts.IgnoreFields(socket.Result{}, "HTTPTimings")) {
 				t.Fatalf("httpRunner.RunTest():\n got = %v\n want = %v", got, tt.want)
 			}
 		})
@@ -725,7 +883,7 @@ func TestIcmpRunner_RunTest(t *testing.T) {
 			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 			defer cancel()
 			got := tt.runner.RunTest(ctx, tt.args.sock)
-			if !cmp.Equal(got, tt.want, cmpopts.EquateErrors()) {
+			if !cmp.Equal(got, tt.want, cmpopts.EquateErrors(), cmpopts.IgnoreFields(socket.Result{}, "ICMPStats")) {
 				t.Fatalf("icmpRunner.RunTest():\n got = %v\n want = %v", got, tt.want)
 			}
 		})
@@ -753,3 +911,133 @@ func TestIcmpRunner_RunTest_Windows(t *testing.T) {
 		t.Error("expected error, got nil")
 	}
 }
+
+// newTestServerSocket returns a socket pointing to the given httptest server.
+func newTestServerSocket(t *testing.T, server *httptest.Server) socket.Socket {
+	t.Helper()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("failed to parse test server URL: %v", err)
+	}
+
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		t.Fatalf("failed to parse test server port: %v", err)
+	}
+
+	return socket.Socket{
+		ID:                "test_server",
+		Name:              "Test Server",
+		Host:              u.Scheme + "://" + u.Hostname(),
+		Port:              port,
+		ExpectedHTTPCodes: []int{200},
+		PathHTTP:          "/",
+	}
+}
+
+func TestHttpRunner_RunTest_RequestOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if r.Method != http.MethodPost ||
+			r.Header.Get("Authorization") != "Bearer abc" ||
+			r.Header.Get("User-Agent") != "dish/"+agentVersion ||
+			string(body) != `{"ping":true}` {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	bodyFile := filepath.Join(t.TempDir(), "body.json")
+	if err := os.WriteFile(bodyFile, []byte(`{"ping":true}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		modify     func(*socket.Socket)
+		wantPassed bool
+		wantCode   int
+	}{
+		{
+			name: "passes with an inline body",
+			modify: func(s *socket.Socket) {
+				s.MethodHTTP = "post"
+				s.HeadersHTTP = map[string]string{"Authorization": "Bearer abc"}
+				s.BodyHTTP = `{"ping":true}`
+			},
+			wantPassed: true,
+			wantCode:   http.StatusOK,
+		},
+		{
+			name: "passes with a body loaded from a file",
+			modify: func(s *socket.Socket) {
+				s.MethodHTTP = http.MethodPost
+				s.HeadersHTTP = map[string]string{"Authorization": "Bearer abc"}
+				s.BodyFileHTTP = bodyFile
+			},
+			wantPassed: true,
+			wantCode:   http.StatusOK,
+		},
+		{
+			name: "fails when the default GET method is used",
+			modify: func(s *socket.Socket) {
+				s.HeadersHTTP = map[string]string{"Authorization": "Bearer abc"}
+			},
+			wantPassed: false,
+			wantCode:   http.StatusBadRequest,
+		},
+		{
+			name: "fails on an unsupported method",
+			modify: func(s *socket.Socket) {
+				s.MethodHTTP = "CONNECT"
+			},
+			wantPassed: false,
+		},
+		{
+			name: "fails when both an inline body and a body file are set",
+			modify: func(s *socket.Socket) {
+				s.MethodHTTP = http.MethodPost
+				s.BodyHTTP = "x"
+				s.BodyFileHTTP = bodyFile
+			},
+			wantPassed: false,
+		},
+		{
+			name: "fails when the body file does not exist",
+			modify: func(s *socket.Socket) {
+				s.MethodHTTP = http.MethodPost
+				s.BodyFileHTTP = filepath.Join(t.TempDir(), "missing.json")
+			},
+			wantPassed: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sock := newTestServerSocket(t, server)
+			tt.modify(&sock)
+
+			runner := httpRunner{client: &http.Client{}, logger: &MockLogger{}}
+			got := runner.RunTest(context.Background(), sock)
+
+			if got.Passed != tt.wantPassed {
+				t.Fatalf("httpRunner.RunTest(): passed = %v, want %v (error: %v)", got.Passed, tt.wantPassed, got.Error)
+			}
+			if !tt.wantPassed && got.Error == nil {
+				t.Fatal("httpRunner.RunTest(): expected an error, got nil")
+			}
+			if got.ResponseCode != tt.wantCode {
+				t.Fatalf("httpRunner.RunTest(): response code = %d, want %d", got.ResponseCode, tt.wantCode)
+			}
+		})
+	}
+}
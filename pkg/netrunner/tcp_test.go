@@ -0,0 +1,123 @@
+package netrunner
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+// newTestLineServer starts a TCP server on a random local port which greets every client with the
+// provided banner and then replies "+PONG" to every "PING" line. Other lines are ignored.
+func newTestLineServer(t *testing.T, banner string) int {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start a TCP listener: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close() //nolint:errcheck
+
+				if banner != "" {
+					if _, err := conn.Write([]byte(banner + "\r\n")); err != nil {
+						return
+					}
+				}
+
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					if scanner.Text() == "PING" {
+						if _, err := conn.Write([]byte("+PONG\r\n")); err != nil {
+							return
+						}
+					}
+				}
+			}()
+		}
+	}()
+
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestTcpRunner_RunTest_SendExpect(t *testing.T) {
+	port := newTestLineServer(t, "SSH-2.0-OpenSSH_9.6")
+
+	tests := []struct {
+		name       string
+		sock       socket.Socket
+		wantPassed bool
+	}{
+		{
+			name:       "passes on an expected banner",
+			sock:       socket.Socket{Host: "127.0.0.1", Port: port, TCPExpectRegex: `^SSH-2\.0-`},
+			wantPassed: true,
+		},
+		{
+			name:       "passes on an expected reply to the sent payload",
+			sock:       socket.Socket{Host: "127.0.0.1", Port: port, TCPSend: "PING\r\n", TCPExpect: "+PONG"},
+			wantPassed: true,
+		},
+		{
+			name:       "passes when only a payload is sent",
+			sock:       socket.Socket{Host: "127.0.0.1", Port: port, TCPSend: "QUIT\r\n"},
+			wantPassed: true,
+		},
+		{
+			name: "fails when the expected reply is not received before the timeout",
+			sock: socket.Socket{Host: "127.0.0.1", Port: port, TCPSend: "ECHO\r\n", TCPExpect: "+PONG"},
+		},
+		{
+			name: "fails when the regex does not match",
+			sock: socket.Socket{Host: "127.0.0.1", Port: port, TCPExpect: "SSH", TCPExpectRegex: `^220 `},
+		},
+		{
+			name: "fails on an invalid regex",
+			sock: socket.Socket{Host: "127.0.0.1", Port: port, TCPExpectRegex: `(`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			runner := tcpRunner{logger: &MockLogger{}}
+
+			got := runner.RunTest(ctx, tt.sock)
+			if got.Passed != tt.wantPassed {
+				t.Fatalf("tcpRunner.RunTest(): passed = %v, want %v (error: %v)", got.Passed, tt.wantPassed, got.Error)
+			}
+		})
+	}
+}
+
+func TestExchange_ConnectionClosed(t *testing.T) {
+	client, server := net.Pipe()
+
+	go func() {
+		_, _ = server.Write([]byte("220 smtp.example.com ESMTP"))
+		_ = server.Close()
+	}()
+
+	sock := socket.Socket{TCPExpect: "250"}
+
+	if err := exchange(context.Background(), client, sock); err == nil {
+		t.Fatal("exchange(): expected an error when the connection is closed before the expected reply")
+	}
+}
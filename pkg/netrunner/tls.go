@@ -0,0 +1,146 @@
+package netrunner
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"time"
+
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+// tlsVersions maps the supported values of socket.TLSMinVersion to TLS version constants.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// hasTLSOptions reports whether any TLS client options are set on the given socket.
+func hasTLSOptions(sock socket.Socket) bool {
+	return sock.TLSMinVersion != "" ||
+		sock.TLSCAFile != "" ||
+		sock.TLSCertFile != "" ||
+		sock.TLSKeyFile != "" ||
+		sock.TLSServerName != "" ||
+		sock.TLSInsecureSkipVerify
+}
+
+// validateCipherSuites checks that the given names are names of cipher suites implemented by crypto/tls,
+// e.g. "TLS_AES_128_GCM_SHA256".
+func validateCipherSuites(names []string) error {
+	for _, name := range names {
+		known := slices.ContainsFunc(slices.Concat(tls.CipherSuites(), tls.InsecureCipherSuites()), func(suite *tls.CipherSuite) bool {
+			return suite.Name == name
+		})
+		if !known {
+			return fmt.Errorf("unknown cipher suite %q", name)
+		}
+	}
+
+	return nil
+}
+
+// tlsConfig returns the TLS client configuration for the given socket. It loads the CA bundle and the client
+// certificate key pair if set. The server name is only set if overridden on the socket.
+func tlsConfig(sock socket.Socket) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         sock.TLSServerName,
+		InsecureSkipVerify: sock.TLSInsecureSkipVerify, //nolint:gosec
+	}
+
+	if sock.TLSMinVersion != "" {
+		version, ok := tlsVersions[sock.TLSMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported minimum TLS version: %s", sock.TLSMinVersion)
+		}
+		config.MinVersion = version
+	}
+
+	if sock.TLSCAFile != "" {
+		pem, err := os.ReadFile(sock.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificates found in CA bundle %s", sock.TLSCAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if sock.TLSCertFile != "" || sock.TLSKeyFile != "" {
+		if sock.TLSCertFile == "" || sock.TLSKeyFile == "" {
+			return nil, errors.New("both tls_cert_file and tls_key_file must be set for a client certificate")
+		}
+
+		cert, err := tls.LoadX509KeyPair(sock.TLSCertFile, sock.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// tlsHandshake performs a TLS handshake over the provided connection and verifies the negotiated
+// cipher suite against the ones allowed for the given socket. Unless overridden, the socket host is
+// used as the server name. The established TLS connection is returned.
+func tlsHandshake(ctx context.Context, conn net.Conn, sock socket.Socket) (*tls.Conn, error) {
+	config, err := tlsConfig(sock)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.ServerName == "" {
+		config.ServerName = hostname(sock.Host)
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, fmt.Errorf("TLS handshake failed: %w", err)
+	}
+
+	state := tlsConn.ConnectionState()
+	if len(sock.TLSCipherSuites) > 0 && !slices.Contains(sock.TLSCipherSuites, tls.CipherSuiteName(state.CipherSuite)) {
+		return nil, fmt.Errorf(
+			"negotiated cipher suite %s (%s) is not allowed",
+			tls.CipherSuiteName(state.CipherSuite), tls.VersionName(state.Version),
+		)
+	}
+
+	return tlsConn, nil
+}
+
+// checkCertificate extracts the details of the leaf certificate from the provided TLS connection state.
+// A non-nil error is returned if the certificate expires in less than warnDays days.
+func checkCertificate(state *tls.ConnectionState, warnDays int) (*socket.Certificate, error) {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil, errors.New("no TLS certificate presented by the server")
+	}
+
+	leaf := state.PeerCertificates[0]
+
+	cert := &socket.Certificate{
+		NotAfter:      leaf.NotAfter,
+		Issuer:        leaf.Issuer.String(),
+		DaysRemaining: int(time.Until(leaf.NotAfter) / (24 * time.Hour)),
+	}
+
+	if cert.DaysRemaining < warnDays {
+		return cert, fmt.Errorf(
+			"certificate issued by %s expires in %d days (%s), threshold is %d days",
+			cert.Issuer, cert.DaysRemaining, cert.NotAfter.Format(time.RFC3339), warnDays,
+		)
+	}
+
+	return cert, nil
+}
@@ -0,0 +1,422 @@
+package netrunner
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+func TestTLSConfig(t *testing.T) {
+	cert := newTestCertificate(t, time.Now().Add(24*time.Hour))
+	certFile, keyFile := writeTestCertificate(t, cert)
+
+	tests := []struct {
+		name           string
+		sock           socket.Socket
+		wantServerName string
+		wantMinVersion uint16
+		wantRootCAs    bool
+		wantClientCert bool
+		wantErr        bool
+	}{
+		{
+			name: "leaves the server name empty unless overridden",
+			sock: socket.Socket{Host: "ldap.example.com"},
+		},
+		{
+			name:           "overrides the server name",
+			sock:           socket.Socket{Host: "10.0.0.1", TLSServerName: "ldap.example.com"},
+			wantServerName: "ldap.example.com",
+		},
+		{
+			name:           "sets the minimum TLS version",
+			sock:           socket.Socket{Host: "ldap.example.com", TLSMinVersion: "1.2"},
+			wantMinVersion: tls.VersionTLS12,
+		},
+		{
+			name:        "loads the CA bundle",
+			sock:        socket.Socket{Host: "ldap.example.com", TLSCAFile: certFile},
+			wantRootCAs: true,
+		},
+		{
+			name:           "loads the client certificate",
+			sock:           socket.Socket{Host: "ldap.example.com", TLSCertFile: certFile, TLSKeyFile: keyFile},
+			wantClientCert: true,
+		},
+		{
+			name:    "fails on an unsupported minimum TLS version",
+			sock:    socket.Socket{Host: "ldap.example.com", TLSMinVersion: "2.0"},
+			wantErr: true,
+		},
+		{
+			name:    "fails on a missing CA bundle",
+			sock:    socket.Socket{Host: "ldap.example.com", TLSCAFile: filepath.Join(t.TempDir(), "ca.pem")},
+			wantErr: true,
+		},
+		{
+			name:    "fails on a CA bundle without certificates",
+			sock:    socket.Socket{Host: "ldap.example.com", TLSCAFile: keyFile},
+			wantErr: true,
+		},
+		{
+			name:    "fails on a client certificate without a key",
+			sock:    socket.Socket{Host: "ldap.example.com", TLSCertFile: certFile},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tlsConfig(tt.sock)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("tlsConfig(): error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got.ServerName != tt.wantServerName {
+				t.Errorf("tlsConfig(): server name = %q, want %q", got.ServerName, tt.wantServerName)
+			}
+
+			if got.MinVersion != tt.wantMinVersion {
+				t.Errorf("tlsConfig(): min version = %d, want %d", got.MinVersion, tt.wantMinVersion)
+			}
+
+			if (got.RootCAs != nil) != tt.wantRootCAs {
+				t.Errorf("tlsConfig(): root CAs set = %v, want %v", got.RootCAs != nil, tt.wantRootCAs)
+			}
+
+			if (len(got.Certificates) > 0) != tt.wantClientCert {
+				t.Errorf("tlsConfig(): client certificate set = %v, want %v", len(got.Certificates) > 0, tt.wantClientCert)
+			}
+		})
+	}
+}
+
+func TestTcpRunner_RunTest_TLS(t *testing.T) {
+	cert := newTestCertificate(t, time.Now().Add(365*24*time.Hour))
+	caFile, _ := writeTestCertificate(t, cert)
+	addr := newTLSListener(t, &tls.Config{Certificates: []tls.Certificate{cert}, MaxVersion: tls.VersionTLS12})
+
+	tests := []struct {
+		name       string
+		sock       socket.Socket
+		wantPassed bool
+	}{
+		{
+			name:       "passes with a trusted CA bundle",
+			sock:       socket.Socket{Host: "127.0.0.1", Port: addr.Port, TLS: true, TLSCAFile: caFile},
+			wantPassed: true,
+		},
+		{
+			name:       "passes with a server name override",
+			sock:       socket.Socket{Host: "127.0.0.1", Port: addr.Port, TLS: true, TLSCAFile: caFile, TLSServerName: "localhost"},
+			wantPassed: true,
+		},
+		{
+			name:       "passes with verification disabled",
+			sock:       socket.Socket{Host: "127.0.0.1", Port: addr.Port, TLS: true, TLSInsecureSkipVerify: true},
+			wantPassed: true,
+		},
+		{
+			name: "passes with an allowed cipher suite",
+			sock: socket.Socket{
+				Host: "127.0.0.1", Port: addr.Port, TLS: true, TLSCAFile: caFile,
+				TLSCipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256"},
+			},
+			wantPassed: true,
+		},
+		{
+			name: "fails on a disallowed cipher suite",
+			sock: socket.Socket{
+				Host: "127.0.0.1", Port: addr.Port, TLS: true, TLSCAFile: caFile,
+				TLSCipherSuites: []string{"TLS_AES_128_GCM_SHA256"},
+			},
+		},
+		{
+			name: "fails when the minimum TLS version is not supported by the server",
+			sock: socket.Socket{Host: "127.0.0.1", Port: addr.Port, TLS: true, TLSCAFile: caFile, TLSMinVersion: "1.3"},
+		},
+		{
+			name: "fails on a server name mismatch",
+			sock: socket.Socket{Host: "127.0.0.1", Port: addr.Port, TLS: true, TLSCAFile: caFile, TLSServerName: "example.com"},
+		},
+		{
+			name: "fails on an untrusted certificate",
+			sock: socket.Socket{Host: "127.0.0.1", Port: addr.Port, TLS: true},
+		},
+		{
+			name: "fails on an unsupported minimum TLS version",
+			sock: socket.Socket{Host: "127.0.0.1", Port: addr.Port, TLS: true, TLSMinVersion: "0.9"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := tcpRunner{logger: &MockLogger{}}
+
+			got := runner.RunTest(context.Background(), tt.sock)
+			if got.Passed != tt.wantPassed {
+				t.Fatalf("tcpRunner.RunTest(): passed = %v, want %v (error: %v)", got.Passed, tt.wantPassed, got.Error)
+			}
+		})
+	}
+}
+
+func TestHttpRunner_RunTest_MutualTLS(t *testing.T) {
+	serverCert := newTestCertificate(t, time.Now().Add(24*time.Hour))
+	clientCert := newTestCertificate(t, time.Now().Add(24*time.Hour))
+	caFile, _ := writeTestCertificate(t, serverCert)
+	certFile, keyFile := writeTestCertificate(t, clientCert)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert.Leaf)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		modify     func(*socket.Socket)
+		wantPassed bool
+	}{
+		{
+			name: "passes with a trusted CA bundle and a client certificate",
+			modify: func(s *socket.Socket) {
+				s.TLSCAFile = caFile
+				s.TLSCertFile = certFile
+				s.TLSKeyFile = keyFile
+			},
+			wantPassed: true,
+		},
+		{
+			name: "fails without a client certificate",
+			modify: func(s *socket.Socket) {
+				s.TLSCAFile = caFile
+			},
+		},
+		{
+			name: "fails without a trusted CA bundle",
+			modify: func(s *socket.Socket) {
+				s.TLSCertFile = certFile
+				s.TLSKeyFile = keyFile
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sock := newTestServerSocket(t, server)
+			tt.modify(&sock)
+
+			runner, err := NewNetRunner(sock, &MockLogger{})
+			if err != nil {
+				t.Fatalf("NewNetRunner(): unexpected error: %v", err)
+			}
+
+			got := runner.RunTest(context.Background(), sock)
+			if got.Passed != tt.wantPassed {
+				t.Fatalf("httpRunner.RunTest(): passed = %v, want %v (error: %v)", got.Passed, tt.wantPassed, got.Error)
+			}
+		})
+	}
+}
+
+func TestNewRunner_CipherSuites(t *testing.T) {
+	tests := []struct {
+		name    string
+		sock    socket.Socket
+		wantErr bool
+	}{
+		{
+			name: "known cipher suites are accepted for TCP",
+			sock: socket.Socket{ID: "tcp", Host: "localhost", Port: 443, TLS: true, TLSCipherSuites: []string{"TLS_AES_128_GCM_SHA256", "TLS_RSA_WITH_RC4_128_SHA"}},
+		},
+		{
+			name:    "unknown cipher suites are rejected for TCP",
+			sock:    socket.Socket{ID: "tcp", Host: "localhost", Port: 443, TLS: true, TLSCipherSuites: []string{"TLS_AES_128_GCM_SHA256", "TLS_AES_128_GCM"}},
+			wantErr: true,
+		},
+		{
+			name:    "cipher suites are rejected for HTTP",
+			sock:    socket.Socket{ID: "http", Host: "https://localhost", Port: 443, TLSCipherSuites: []string{"TLS_AES_128_GCM_SHA256"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewNetRunner(tt.sock, &MockLogger{}); (err != nil) != tt.wantErr {
+				t.Errorf("NewNetRunner(): error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckCertificate(t *testing.T) {
+	tests := []struct {
+		name     string
+		notAfter time.Time
+		warnDays int
+		wantDays int
+		wantErr  bool
+	}{
+		{
+			name:     "passes when the certificate is valid for longer than the threshold",
+			notAfter: time.Now().Add(30*24*time.Hour + time.Hour),
+			warnDays: 14,
+			wantDays: 30,
+		},
+		{
+			name:     "fails when the certificate expires within the threshold",
+			notAfter: time.Now().Add(5*24*time.Hour + time.Hour),
+			warnDays: 14,
+			wantDays: 5,
+			wantErr:  true,
+		},
+		{
+			name:     "fails when the certificate has already expired",
+			notAfter: time.Now().Add(-48 * time.Hour),
+			warnDays: 1,
+			wantDays: -2,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert := newTestCertificate(t, tt.notAfter)
+			state := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert.Leaf}}
+
+			got, err := checkCertificate(state, tt.warnDays)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkCertificate(): error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got == nil {
+				t.Fatal("checkCertificate(): expected certificate details, got nil")
+			}
+
+			if got.DaysRemaining != tt.wantDays {
+				t.Errorf("checkCertificate(): days remaining = %d, want %d", got.DaysRemaining, tt.wantDays)
+			}
+
+			if got.Issuer != "CN=dish test" {
+				t.Errorf("checkCertificate(): issuer = %q, want %q", got.Issuer, "CN=dish test")
+			}
+		})
+	}
+
+	t.Run("fails when no certificate is presented", func(t *testing.T) {
+		if _, err := checkCertificate(nil, 14); err == nil {
+			t.Fatal("checkCertificate(): expected an error, got nil")
+		}
+	})
+}
+
+func TestHttpRunner_RunTest_CertExpiry(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	sock := newTestServerSocket(t, server)
+	sock.CertExpiryWarnDays = 14
+
+	runner := httpRunner{client: server.Client(), logger: &MockLogger{}}
+	got := runner.RunTest(context.Background(), sock)
+
+	if !got.Passed {
+		t.Fatalf("httpRunner.RunTest(): expected the test to pass, got error: %v", got.Error)
+	}
+
+	if got.Certificate == nil || got.Certificate.NotAfter.IsZero() {
+		t.Fatalf("httpRunner.RunTest(): expected certificate details, got %v", got.Certificate)
+	}
+
+	sock.CertExpiryWarnDays = got.Certificate.DaysRemaining + 1
+	if got := runner.RunTest(context.Background(), sock); got.Passed || got.Error == nil {
+		t.Fatal("httpRunner.RunTest(): expected the test to fail below the expiry threshold")
+	}
+}
+
+func TestHttpRunner_RunTest_CertExpiryRedirect(t *testing.T) {
+	newServer := func(days int, handler http.HandlerFunc) *httptest.Server {
+		server := httptest.NewUnstartedServer(handler)
+		server.TLS = &tls.Config{Certificates: []tls.Certificate{newTestCertificate(t, time.Now().Add(time.Duration(days)*24*time.Hour+time.Hour))}}
+		server.StartTLS()
+		t.Cleanup(server.Close)
+		return server
+	}
+
+	target := newServer(399, func(w http.ResponseWriter, r *http.Request) {})
+	origin := newServer(2, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusMovedPermanently)
+	})
+
+	sock := newTestServerSocket(t, origin)
+	sock.CertExpiryWarnDays = 14
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}} //nolint:gosec
+	runner := httpRunner{client: client, logger: &MockLogger{}}
+
+	// The certificate of the socket host is checked, not the one of the redirect target.
+	got := runner.RunTest(context.Background(), sock)
+	if got.Passed || got.Error == nil {
+		t.Errorf("httpRunner.RunTest(): expected the test to fail on the certificate of the socket host")
+	}
+
+	if got.Certificate == nil || got.Certificate.DaysRemaining != 2 {
+		t.Errorf("httpRunner.RunTest(): expected the certificate of the socket host, got %v", got.Certificate)
+	}
+}
+
+func TestTcpRunner_RunTest_CertExpiry(t *testing.T) {
+	cert := newTestCertificate(t, time.Now().Add(10*24*time.Hour+time.Hour))
+	caFile, _ := writeTestCertificate(t, cert)
+	addr := newTLSListener(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+
+	sock := socket.Socket{
+		ID:                 "local_tls",
+		Name:               "Local TLS",
+		Host:               "127.0.0.1",
+		Port:               addr.Port,
+		CertExpiryWarnDays: 7,
+		TLSCAFile:          caFile,
+	}
+
+	runner := tcpRunner{logger: &MockLogger{}}
+
+	got := runner.RunTest(context.Background(), sock)
+	if !got.Passed {
+		t.Fatalf("tcpRunner.RunTest(): expected the test to pass, got error: %v", got.Error)
+	}
+
+	if got.Certificate == nil || got.Certificate.DaysRemaining != 10 {
+		t.Fatalf("tcpRunner.RunTest(): unexpected certificate details: %v", got.Certificate)
+	}
+
+	sock.CertExpiryWarnDays = 14
+	if got := runner.RunTest(context.Background(), sock); got.Passed || got.Error == nil {
+		t.Fatal("tcpRunner.RunTest(): expected the test to fail below the expiry threshold")
+	}
+
+	// Without the CA bundle, the self-signed certificate is not trusted and the handshake itself fails.
+	sock.TLSCAFile = ""
+	if got := runner.RunTest(context.Background(), sock); got.Passed || got.Certificate != nil {
+		t.Fatal("tcpRunner.RunTest(): expected the test to fail on an untrusted certificate")
+	}
+}
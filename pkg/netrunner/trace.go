@@ -0,0 +1,98 @@
+package netrunner
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+// httpTracer records the timestamps of the individual phases of a HTTP request using httptrace. If redirects are
+// followed, only the phases of the last request are kept, i.e. of the request whose response is checked.
+type httpTracer struct {
+	mu sync.Mutex
+
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+}
+
+// withTrace returns a copy of the provided context with a httptrace.ClientTrace recording the request phases.
+// The start of a request is considered to be the time a connection for it is requested. The phases recorded
+// so far are dropped then, so that the phases of a redirected request do not span the previous requests.
+func (t *httpTracer) withTrace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn:  func(string) { t.reset() },
+		DNSStart: func(httptrace.DNSStartInfo) { t.record(&t.dnsStart, false) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.record(&t.dnsDone, true) },
+		ConnectStart: func(_, _ string) {
+			t.record(&t.connectStart, false)
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				t.record(&t.connectDone, true)
+			}
+		},
+		TLSHandshakeStart:    func() { t.record(&t.tlsStart, false) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.record(&t.tlsDone, true) },
+		GotFirstResponseByte: func() { t.record(&t.firstByte, false) },
+	})
+}
+
+// reset drops the recorded phases and sets the start of a new request to the current time.
+func (t *httpTracer) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.start = time.Now()
+	t.dnsStart, t.dnsDone = time.Time{}, time.Time{}
+	t.connectStart, t.connectDone = time.Time{}, time.Time{}
+	t.tlsStart, t.tlsDone = time.Time{}, time.Time{}
+	t.firstByte = time.Time{}
+}
+
+// record stores the current time in the provided field. If overwrite is false, only the first occurrence is kept.
+// Some hooks can be called multiple times, e.g. ConnectStart when dialing multiple addresses in parallel.
+func (t *httpTracer) record(field *time.Time, overwrite bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if overwrite || field.IsZero() {
+		*field = time.Now()
+	}
+}
+
+// timings returns the durations of the recorded request phases.
+func (t *httpTracer) timings() *socket.HTTPTimings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	timings := &socket.HTTPTimings{
+		DNSLookup:    elapsed(t.dnsStart, t.dnsDone),
+		TCPConnect:   elapsed(t.connectStart, t.connectDone),
+		TLSHandshake: elapsed(t.tlsStart, t.tlsDone),
+	}
+
+	if !t.firstByte.IsZero() {
+		timings.TimeToFirstByte = t.firstByte.Sub(t.start)
+	}
+
+	return timings
+}
+
+// elapsed returns the duration between start and end, or zero if either of them was not recorded.
+func elapsed(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+
+	return end.Sub(start)
+}
@@ -0,0 +1,101 @@
+package netrunner
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHttpRunner_RunTest_Timings(t *testing.T) {
+	cert := newTestCertificate(t, time.Now().Add(24*time.Hour))
+	caFile, _ := writeTestCertificate(t, cert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	server.StartTLS()
+	defer server.Close()
+
+	// The host name is used instead of the IP address so that a DNS lookup takes place.
+	sock := newTestServerSocket(t, server)
+	sock.Host = "https://localhost"
+	sock.TLSCAFile = caFile
+
+	client, err := newHTTPClient(sock)
+	if err != nil {
+		t.Fatalf("newHTTPClient(): unexpected error: %v", err)
+	}
+
+	runner := httpRunner{client: client, logger: &MockLogger{}}
+
+	got := runner.RunTest(context.Background(), sock)
+	if !got.Passed {
+		t.Fatalf("httpRunner.RunTest(): expected the test to pass, got error: %v", got.Error)
+	}
+
+	timings := got.HTTPTimings
+	if timings == nil {
+		t.Fatal("httpRunner.RunTest(): expected HTTP timings, got nil")
+	}
+
+	if timings.DNSLookup <= 0 || timings.TCPConnect <= 0 || timings.TLSHandshake <= 0 {
+		t.Errorf("httpRunner.RunTest(): expected non-zero DNS, connect and TLS timings, got %+v", timings)
+	}
+
+	if timings.TimeToFirstByte < 10*time.Millisecond {
+		t.Errorf("httpRunner.RunTest(): time to first byte = %v, want at least 10ms", timings.TimeToFirstByte)
+	}
+}
+
+func TestHttpRunner_RunTest_TimingsRedirect(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+
+	// The origin is slow to redirect, which must not be included in the timings of the redirected request.
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		http.Redirect(w, r, target.URL, http.StatusFound)
+	}))
+	defer origin.Close()
+
+	runner := httpRunner{client: &http.Client{}, logger: &MockLogger{}}
+
+	got := runner.RunTest(context.Background(), newTestServerSocket(t, origin))
+	if !got.Passed {
+		t.Fatalf("httpRunner.RunTest(): expected the test to pass, got error: %v", got.Error)
+	}
+
+	timings := got.HTTPTimings
+	if timings == nil {
+		t.Fatal("httpRunner.RunTest(): expected HTTP timings, got nil")
+	}
+
+	if timings.TCPConnect <= 0 || timings.TCPConnect >= 300*time.Millisecond {
+		t.Errorf("httpRunner.RunTest(): expected the TCP connect time of the redirected request, got %v", timings.TCPConnect)
+	}
+
+	if timings.TimeToFirstByte <= 0 || timings.TimeToFirstByte >= 300*time.Millisecond {
+		t.Errorf("httpRunner.RunTest(): expected the time to first byte of the redirected request, got %v", timings.TimeToFirstByte)
+	}
+}
+
+func TestElapsed(t *testing.T) {
+	start := time.Now()
+	end := start.Add(time.Second)
+
+	if got := elapsed(start, end); got != time.Second {
+		t.Errorf("elapsed() = %v, want %v", got, time.Second)
+	}
+
+	if got := elapsed(time.Time{}, end); got != 0 {
+		t.Errorf("elapsed() = %v, want 0 when the start is not recorded", got)
+	}
+
+	if got := elapsed(start, time.Time{}); got != 0 {
+		t.Errorf("elapsed() = %v, want 0 when the end is not recorded", got)
+	}
+}
@@ -0,0 +1,356 @@
+package netrunner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/cookiejar"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.vxn.dev/dish/pkg/logger"
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+// transactionVarRegex matches references to extracted values, e.g. {{token}}.
+var transactionVarRegex = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+)\s*\}\}`)
+
+// transactionVarNameRegex matches valid names of extracted values.
+var transactionVarNameRegex = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// transactionRunner checks a sequence of HTTP requests made one after another. It uses the client of a
+// httpRunner configured for the socket, so that the TLS, proxy and auth settings of the socket apply to all steps.
+type transactionRunner struct {
+	http   *httpRunner
+	logger logger.Logger
+}
+
+// newTransactionRunner returns a new transactionRunner. A non-nil error is returned if the socket has no steps,
+// sets an assertion which only applies to plain HTTP checks, an extraction rule of a step is not valid or a HTTP
+// client cannot be configured for the socket.
+func newTransactionRunner(sock socket.Socket, logger logger.Logger) (NetRunner, error) {
+	if len(sock.HTTPSteps) == 0 {
+		return nil, fmt.Errorf("protocol %s requires at least one step in http_steps for the socket %s", ProtocolHTTPTransaction, sock.ID)
+	}
+
+	if field := unsupportedTransactionField(sock); field != "" {
+		return nil, fmt.Errorf("protocol %s does not support %s for the socket %s, set the assertions on the individual http_steps instead", ProtocolHTTPTransaction, field, sock.ID)
+	}
+
+	for i, step := range sock.HTTPSteps {
+		for _, extraction := range step.Extract {
+			if err := validateExtraction(extraction); err != nil {
+				return nil, fmt.Errorf("invalid extraction rule in step %s of the socket %s: %w", stepName(i, step), sock.ID, err)
+			}
+		}
+	}
+
+	runner, err := newHTTPRunner(sock, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &transactionRunner{http: runner.(*httpRunner), logger: logger}, nil
+}
+
+// unsupportedTransactionField returns the JSON name of the first socket-level assertion set on the socket which
+// a transaction would ignore, or an empty string if there is none. The responses of a transaction are only checked
+// against the assertions of the individual steps.
+func unsupportedTransactionField(sock socket.Socket) string {
+	fields := []struct {
+		name string
+		set  bool
+	}{
+		{"expected_http_code_array", len(sock.ExpectedHTTPCodes) > 0},
+		{"expected_body_contains", sock.ExpectedBodyContains != ""},
+		{"expected_body_not_contains", sock.ExpectedBodyNotContains != ""},
+		{"expected_body_regex", sock.ExpectedBodyRegex != ""},
+		{"expected_json", len(sock.ExpectedJSON) > 0},
+		{"expected_headers", len(sock.ExpectedHeaders) > 0},
+		{"expected_final_url", sock.ExpectedFinalURL != ""},
+		{"expected_location", sock.ExpectedLocation != ""},
+		{"cert_expiry_warn_days", sock.CertExpiryWarnDays > 0},
+	}
+
+	for _, field := range fields {
+		if field.set {
+			return field.name
+		}
+	}
+
+	return ""
+}
+
+// validateExtraction checks that the extraction rule has a valid name and exactly one source of the value.
+func validateExtraction(extraction socket.HTTPExtraction) error {
+	if !transactionVarNameRegex.MatchString(extraction.Name) {
+		return fmt.Errorf("name %q must only contain letters, digits and underscores", extraction.Name)
+	}
+
+	sources := 0
+	for _, source := range []string{extraction.JSONPath, extraction.Header, extraction.Regex} {
+		if source != "" {
+			sources++
+		}
+	}
+
+	if sources != 1 {
+		return fmt.Errorf("exactly one of json_path, header and regex must be set for %q", extraction.Name)
+	}
+
+	if extraction.Regex != "" {
+		if _, err := regexp.Compile(extraction.Regex); err != nil {
+			return fmt.Errorf("invalid regex for %q: %w", extraction.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// stepName returns the name of the i-th step used in errors: its number followed by its name, if any.
+func stepName(i int, step socket.HTTPStep) string {
+	if step.Name == "" {
+		return strconv.Itoa(i + 1)
+	}
+
+	return fmt.Sprintf("%d (%s)", i+1, step.Name)
+}
+
+// RunTest is used to test HTTP transactions. It makes the requests of the socket steps one after another
+// using a cookie jar shared by the steps. Values extracted from a response are substituted for their
+// references in the following steps. The test passes if the responses to all steps satisfy their assertions.
+// Otherwise, the error of the result names the first step which failed.
+func (runner *transactionRunner) RunTest(ctx context.Context, sock socket.Socket) socket.Result {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return socket.Result{Socket: sock, Error: err}
+	}
+
+	client := *runner.http.client
+	client.Jar = jar
+
+	result := socket.Result{Socket: sock}
+	vars := make(map[string]string)
+
+	for i, step := range sock.HTTPSteps {
+		runner.logger.Debugf("HTTP transaction runner: step %s of %s", stepName(i, step), sock.ID)
+
+		result.ResponseCode, err = runner.runStep(ctx, &client, sock, step, vars)
+		if err != nil {
+			result.Error = fmt.Errorf("step %s failed: %w", stepName(i, step), err)
+			return result
+		}
+	}
+
+	result.Passed = true
+
+	return result
+}
+
+// prepare obtains the OAuth2 token for the socket before the test, see httpRunner.prepare.
+func (runner *transactionRunner) prepare(ctx context.Context, sock socket.Socket) error {
+	return runner.http.prepare(ctx, sock)
+}
+
+// runStep makes the request of the given step using the provided client and checks the response against the
+// assertions of the step. The values extracted from the response are added to vars. The response status code
+// is returned, or zero if no response was received.
+func (runner *transactionRunner) runStep(ctx context.Context, client *http.Client, sock socket.Socket, step socket.HTTPStep, vars map[string]string) (int, error) {
+	stepSock, target, err := expandStep(sock, step, vars)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := newHTTPRequest(ctx, stepSock, target)
+	if err != nil {
+		return 0, err
+	}
+
+	// An Authorization header set by the step, e.g. with an extracted token, takes precedence over the socket auth.
+	var token string
+	if req.Header.Get("Authorization") == "" {
+		if token, err = runner.http.authorize(ctx, req, sock.AuthHTTP); err != nil {
+			return 0, err
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil {
+			runner.logger.Errorf("failed to close body for %v", cerr)
+		}
+	}()
+
+	if token != "" && resp.StatusCode == http.StatusUnauthorized {
+		oauth2Tokens.invalidate(sock.AuthHTTP, token)
+	}
+
+	codes := step.ExpectedHTTPCodes
+	if len(codes) == 0 {
+		codes = []int{http.StatusOK}
+	}
+
+	expectations := httpExpectations{
+		codes:           codes,
+		bodyContains:    step.ExpectedBodyContains,
+		bodyNotContains: step.ExpectedBodyNotContains,
+		bodyRegex:       step.ExpectedBodyRegex,
+		json:            step.ExpectedJSON,
+		headers:         step.ExpectedHeaders,
+		maxBodyBytes:    sock.MaxBodyBytes,
+	}
+
+	if err := expectations.checkCode(resp.StatusCode); err != nil {
+		return resp.StatusCode, err
+	}
+
+	if err := expectations.checkHeaders(resp.Header); err != nil {
+		return resp.StatusCode, err
+	}
+
+	var body []byte
+	if expectations.needsBody() || extractsFromBody(step.Extract) {
+		if body, err = expectations.readBody(resp.Body); err != nil {
+			return resp.StatusCode, err
+		}
+	}
+
+	if err := expectations.checkBody(body); err != nil {
+		return resp.StatusCode, err
+	}
+
+	return resp.StatusCode, extractValues(resp.Header, body, step.Extract, vars)
+}
+
+// expandStep returns a copy of the socket with the request options of the given step and the target URL of the
+// step, with references to extracted values substituted in the path, header values and body. The headers of the
+// step are added to the headers of the socket.
+func expandStep(sock socket.Socket, step socket.HTTPStep, vars map[string]string) (socket.Socket, string, error) {
+	path, err := expandVars(step.Path, vars)
+	if err != nil {
+		return sock, "", err
+	}
+
+	body, err := expandVars(step.Body, vars)
+	if err != nil {
+		return sock, "", err
+	}
+
+	headers := maps.Clone(sock.HeadersHTTP)
+	if headers == nil {
+		headers = make(map[string]string, len(step.Headers))
+	}
+
+	for name, value := range step.Headers {
+		if headers[name], err = expandVars(value, vars); err != nil {
+			return sock, "", err
+		}
+	}
+
+	sock.MethodHTTP = step.Method
+	sock.HeadersHTTP = headers
+	sock.BodyHTTP = body
+	sock.BodyFileHTTP = ""
+
+	target := path
+	if !httpURLRegex.MatchString(path) {
+		target = sock.Host + ":" + strconv.Itoa(sock.Port) + path
+	}
+
+	return sock, target, nil
+}
+
+// expandVars substitutes the values in vars for their references in s. A non-nil error is returned if s
+// references a value which has not been extracted.
+func expandVars(s string, vars map[string]string) (string, error) {
+	var missing []string
+
+	expanded := transactionVarRegex.ReplaceAllStringFunc(s, func(ref string) string {
+		name := transactionVarRegex.FindStringSubmatch(ref)[1]
+
+		value, ok := vars[name]
+		if !ok {
+			missing = append(missing, name)
+		}
+
+		return value
+	})
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("undefined values referenced: %s", strings.Join(missing, ", "))
+	}
+
+	return expanded, nil
+}
+
+// extractsFromBody reports whether any of the extraction rules reads the response body.
+func extractsFromBody(extractions []socket.HTTPExtraction) bool {
+	for _, extraction := range extractions {
+		if extraction.JSONPath != "" || extraction.Regex != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// extractValues extracts the values described by the extraction rules from the response headers and body and
+// adds them to vars. JSON values other than strings are stored in their JSON encoding. A non-nil error is
+// returned if a value is not found.
+func extractValues(header http.Header, body []byte, extractions []socket.HTTPExtraction, vars map[string]string) error {
+	var doc any
+	parsed := false
+
+	for _, extraction := range extractions {
+		switch {
+		case extraction.Header != "":
+			value := header.Get(extraction.Header)
+			if value == "" {
+				return fmt.Errorf("failed to extract %q: response header %q not found", extraction.Name, extraction.Header)
+			}
+			vars[extraction.Name] = value
+
+		case extraction.JSONPath != "":
+			if !parsed {
+				if err := json.Unmarshal(body, &doc); err != nil {
+					return fmt.Errorf("failed to extract %q: failed to parse response body as JSON: %w", extraction.Name, err)
+				}
+				parsed = true
+			}
+
+			value, err := lookupJSONPath(doc, extraction.JSONPath)
+			if err != nil {
+				return fmt.Errorf("failed to extract %q: %w", extraction.Name, err)
+			}
+
+			if s, ok := value.(string); ok {
+				vars[extraction.Name] = s
+			} else {
+				encoded, _ := json.Marshal(value)
+				vars[extraction.Name] = string(encoded)
+			}
+
+		case extraction.Regex != "":
+			// The expression was validated when the runner was created.
+			match := regexp.MustCompile(extraction.Regex).FindSubmatch(body)
+			if match == nil {
+				return fmt.Errorf("failed to extract %q: response body does not match %q", extraction.Name, extraction.Regex)
+			}
+
+			if len(match) > 1 {
+				vars[extraction.Name] = string(match[1])
+			} else {
+				vars[extraction.Name] = string(match[0])
+			}
+		}
+	}
+
+	return nil
+}
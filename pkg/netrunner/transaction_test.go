@@ -0,0 +1,355 @@
+package netrunner
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+// newTestLoginServer starts a HTTP server with a login flow: POST /login with valid credentials sets a session
+// cookie and returns a token, which GET /api/items requires together with the cookie.
+func newTestLoginServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /login", func(w http.ResponseWriter, r *http.Request) {
+		var creds struct {
+			User     string `json:"user"`
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&creds); err != nil || creds.User != "dish" || creds.Password != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+		w.Header().Set("X-Request-Id", "req-1")
+		_, _ = w.Write([]byte(`{"data": {"token": "t0k", "expires_in": 60}}`))
+	})
+
+	mux.HandleFunc("GET /api/items", func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("session")
+		if err != nil || cookie.Value != "abc" || r.Header.Get("Authorization") != "Bearer t0k" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"items": [{"name": "first"}], "request": "` + r.Header.Get("X-Request-Id") + `"}`))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestTransactionRunner_RunTest(t *testing.T) {
+	server := newTestLoginServer(t)
+
+	login := socket.HTTPStep{
+		Name:    "login",
+		Method:  "POST",
+		Path:    "/login",
+		Headers: map[string]string{"Content-Type": "application/json"},
+		Body:    `{"user": "dish", "password": "s3cret"}`,
+		Extract: []socket.HTTPExtraction{
+			{Name: "token", JSONPath: "data.token"},
+			{Name: "request_id", Header: "X-Request-Id"},
+		},
+	}
+
+	items := socket.HTTPStep{
+		Name:         "items",
+		Path:         "/api/items",
+		Headers:      map[string]string{"Authorization": "Bearer {{token}}", "X-Request-Id": "{{ request_id }}"},
+		ExpectedJSON: []socket.JSONAssertion{{Path: "items.0.name", Equals: json.RawMessage(`"first"`)}, {Path: "request", Equals: json.RawMessage(`"req-1"`)}},
+	}
+
+	tests := []struct {
+		name      string
+		steps     []socket.HTTPStep
+		wantPass  bool
+		wantCode  int
+		wantError string
+	}{
+		{
+			name:     "login and call a protected endpoint",
+			steps:    []socket.HTTPStep{login, items},
+			wantPass: true,
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "failed login",
+			steps: []socket.HTTPStep{
+				{Name: "login", Method: "POST", Path: "/login", Body: `{"user": "dish", "password": "wrong"}`},
+				items,
+			},
+			wantCode:  http.StatusUnauthorized,
+			wantError: "step 1 (login) failed: expected codes: [200], got 401",
+		},
+		{
+			name: "failed assertion on the second step",
+			steps: []socket.HTTPStep{login, {
+				Path:                 "/api/items",
+				Headers:              map[string]string{"Authorization": "Bearer {{token}}"},
+				ExpectedBodyContains: "second",
+			}},
+			wantCode:  http.StatusOK,
+			wantError: "step 2 failed: expected response body to contain \"second\"",
+		},
+		{
+			name:      "reference to a value which was not extracted",
+			steps:     []socket.HTTPStep{{Path: "/api/items/{{item_id}}"}},
+			wantError: "step 1 failed: undefined values referenced: item_id",
+		},
+		{
+			name: "value not found in the response",
+			steps: []socket.HTTPStep{{
+				Name:    "login",
+				Method:  "POST",
+				Path:    "/login",
+				Body:    `{"user": "dish", "password": "s3cret"}`,
+				Extract: []socket.HTTPExtraction{{Name: "token", JSONPath: "data.access_token"}},
+			}},
+			wantCode:  http.StatusOK,
+			wantError: `step 1 (login) failed: failed to extract "token": JSON path "data.access_token" not found`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sock := newTestServerSocket(t, server)
+			sock.Protocol = ProtocolHTTPTransaction
+			sock.ExpectedHTTPCodes = nil
+			sock.HTTPSteps = tt.steps
+
+			runner, err := NewNetRunner(sock, &MockLogger{})
+			if err != nil {
+				t.Fatalf("NewNetRunner(): unexpected error: %v", err)
+			}
+
+			got := runner.RunTest(context.Background(), sock)
+
+			if got.Passed != tt.wantPass {
+				t.Errorf("transactionRunner.RunTest(): expected passed = %v, got %v (error: %v)", tt.wantPass, got.Passed, got.Error)
+			}
+
+			if got.ResponseCode != tt.wantCode {
+				t.Errorf("transactionRunner.RunTest(): expected response code %d, got %d", tt.wantCode, got.ResponseCode)
+			}
+
+			if tt.wantError != "" && (got.Error == nil || got.Error.Error() != tt.wantError) {
+				t.Errorf("transactionRunner.RunTest(): expected error %q, got %v", tt.wantError, got.Error)
+			}
+		})
+	}
+}
+
+func TestTransactionRunner_RunTest_CookiesNotShared(t *testing.T) {
+	server := newTestLoginServer(t)
+
+	sock := newTestServerSocket(t, server)
+	sock.Protocol = ProtocolHTTPTransaction
+	sock.ExpectedHTTPCodes = nil
+	sock.HTTPSteps = []socket.HTTPStep{{Method: "POST", Path: "/login", Body: `{"user": "dish", "password": "s3cret"}`}}
+
+	runner, err := NewNetRunner(sock, &MockLogger{})
+	if err != nil {
+		t.Fatalf("NewNetRunner(): unexpected error: %v", err)
+	}
+
+	if got := runner.RunTest(context.Background(), sock); !got.Passed {
+		t.Fatalf("transactionRunner.RunTest(): expected the login to pass, got error: %v", got.Error)
+	}
+
+	// Each run starts with an empty cookie jar, so the session of the previous run is not sent.
+	sock.HTTPSteps = []socket.HTTPStep{{Path: "/api/items", Headers: map[string]string{"Authorization": "Bearer t0k"}}}
+
+	if got := runner.RunTest(context.Background(), sock); got.Passed {
+		t.Errorf("transactionRunner.RunTest(): expected the request without a session to fail")
+	}
+}
+
+func TestNewTransactionRunner(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []socket.HTTPStep
+		wantErr bool
+	}{
+		{
+			name:  "valid extraction rules",
+			steps: []socket.HTTPStep{{Extract: []socket.HTTPExtraction{{Name: "token", JSONPath: "token"}, {Name: "csrf_1", Regex: `name="csrf" value="(\w+)"`}}}},
+		},
+		{
+			name:    "no steps",
+			wantErr: true,
+		},
+		{
+			name:    "extraction without a source",
+			steps:   []socket.HTTPStep{{Extract: []socket.HTTPExtraction{{Name: "token"}}}},
+			wantErr: true,
+		},
+		{
+			name:    "extraction with two sources",
+			steps:   []socket.HTTPStep{{Extract: []socket.HTTPExtraction{{Name: "token", JSONPath: "token", Header: "X-Token"}}}},
+			wantErr: true,
+		},
+		{
+			name:    "extraction with an invalid name",
+			steps:   []socket.HTTPStep{{Extract: []socket.HTTPExtraction{{Name: "the token", Header: "X-Token"}}}},
+			wantErr: true,
+		},
+		{
+			name:    "extraction with an invalid regex",
+			steps:   []socket.HTTPStep{{Extract: []socket.HTTPExtraction{{Name: "token", Regex: "("}}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sock := socket.Socket{ID: "transaction", Host: "https://example.com", Port: 443, HTTPSteps: tt.steps}
+
+			if _, err := newTransactionRunner(sock, &MockLogger{}); (err != nil) != tt.wantErr {
+				t.Errorf("newTransactionRunner() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewTransactionRunner_SocketAssertions(t *testing.T) {
+	tests := []struct {
+		name      string
+		modify    func(sock *socket.Socket)
+		wantError string
+	}{
+		{name: "no socket assertions", modify: func(*socket.Socket) {}},
+		{
+			name:      "expected codes",
+			modify:    func(sock *socket.Socket) { sock.ExpectedHTTPCodes = []int{200} },
+			wantError: "expected_http_code_array",
+		},
+		{
+			name:      "expected body",
+			modify:    func(sock *socket.Socket) { sock.ExpectedBodyRegex = "ok" },
+			wantError: "expected_body_regex",
+		},
+		{
+			name:      "expected JSON",
+			modify:    func(sock *socket.Socket) { sock.ExpectedJSON = []socket.JSONAssertion{{Path: "status"}} },
+			wantError: "expected_json",
+		},
+		{
+			name:      "expected headers",
+			modify:    func(sock *socket.Socket) { sock.ExpectedHeaders = []socket.HeaderAssertion{{Name: "X-Id"}} },
+			wantError: "expected_headers",
+		},
+		{
+			name:      "expected final URL",
+			modify:    func(sock *socket.Socket) { sock.ExpectedFinalURL = "https://example.com/" },
+			wantError: "expected_final_url",
+		},
+		{
+			name:      "expected location",
+			modify:    func(sock *socket.Socket) { sock.ExpectedLocation = "/login" },
+			wantError: "expected_location",
+		},
+		{
+			name:      "certificate expiry",
+			modify:    func(sock *socket.Socket) { sock.CertExpiryWarnDays = 14 },
+			wantError: "cert_expiry_warn_days",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sock := socket.Socket{ID: "transaction", Host: "https://example.com", Port: 443, HTTPSteps: []socket.HTTPStep{{Path: "/"}}}
+			tt.modify(&sock)
+
+			_, err := newTransactionRunner(sock, &MockLogger{})
+			if tt.wantError == "" {
+				if err != nil {
+					t.Errorf("newTransactionRunner(): unexpected error: %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantError) || !strings.Contains(err.Error(), "http_steps") {
+				t.Errorf("newTransactionRunner(): expected an error about %s pointing to http_steps, got %v", tt.wantError, err)
+			}
+		})
+	}
+}
+
+func TestExtractValues(t *testing.T) {
+	header := http.Header{"Location": {"/orders/42"}}
+	body := []byte(`<input name="csrf" value="f00"> {"ignored": true}`)
+	jsonBody := []byte(`{"order": {"id": 42, "tags": ["new"], "status": "open"}}`)
+
+	vars := make(map[string]string)
+
+	err := extractValues(header, body, []socket.HTTPExtraction{
+		{Name: "location", Header: "location"},
+		{Name: "csrf", Regex: `name="csrf" value="(\w+)"`},
+		{Name: "input", Regex: `<input[^>]*>`},
+	}, vars)
+	if err != nil {
+		t.Fatalf("extractValues(): unexpected error: %v", err)
+	}
+
+	err = extractValues(header, jsonBody, []socket.HTTPExtraction{
+		{Name: "id", JSONPath: "order.id"},
+		{Name: "tags", JSONPath: "order.tags"},
+		{Name: "status", JSONPath: "order.status"},
+	}, vars)
+	if err != nil {
+		t.Fatalf("extractValues(): unexpected error: %v", err)
+	}
+
+	want := map[string]string{
+		"location": "/orders/42",
+		"csrf":     "f00",
+		"input":    `<input name="csrf" value="f00">`,
+		"id":       "42",
+		"tags":     `["new"]`,
+		"status":   "open",
+	}
+
+	if diff := cmp.Diff(want, vars); diff != "" {
+		t.Errorf("extractValues() mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, extraction := range []socket.HTTPExtraction{
+		{Name: "missing_header", Header: "X-Token"},
+		{Name: "no_match", Regex: `token=(\w+)`},
+		{Name: "not_json", JSONPath: "token"},
+	} {
+		if err := extractValues(header, body, []socket.HTTPExtraction{extraction}, vars); err == nil {
+			t.Errorf("extractValues(): expected an error for %q, got nil", extraction.Name)
+		}
+	}
+}
+
+func TestExpandVars(t *testing.T) {
+	vars := map[string]string{"id": "42", "token": "t0k"}
+
+	got, err := expandVars("/orders/{{id}}?token={{ token }}&raw={id}", vars)
+	if err != nil {
+		t.Fatalf("expandVars(): unexpected error: %v", err)
+	}
+
+	if want := "/orders/42?token=t0k&raw={id}"; got != want {
+		t.Errorf("expandVars() = %q, want %q", got, want)
+	}
+
+	if _, err := expandVars("/orders/{{order_id}}", vars); err == nil || !strings.Contains(err.Error(), "order_id") {
+		t.Errorf("expandVars(): expected an error naming the undefined value, got %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package netrunner
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+
+	"go.vxn.dev/dish/pkg/logger"
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+// maxUDPPayload is the maximum size of a UDP datagram payload.
+const maxUDPPayload = 65507
+
+type udpRunner struct {
+	logger logger.Logger
+}
+
+// newUDPRunner returns a new udpRunner. A non-nil error is returned if the socket host or port is not valid.
+func newUDPRunner(sock socket.Socket, logger logger.Logger) (NetRunner, error) {
+	if sock.Host == "" {
+		return nil, fmt.Errorf("protocol %s requires a host for the socket %s", ProtocolUDP, sock.ID)
+	}
+
+	if sock.Port < 1 || sock.Port > 65535 {
+		return nil, fmt.Errorf("protocol %s requires a port between 1 and 65535 for the socket %s", ProtocolUDP, sock.ID)
+	}
+
+	return &udpRunner{logger: logger}, nil
+}
+
+// RunTest is used to test UDP sockets. It sends the configured payload in a single datagram to the given
+// socket and waits for a reply until the context deadline. The test passes if a reply is received and it
+// matches the expected prefix and regular expression, if set.
+func (runner *udpRunner) RunTest(ctx context.Context, sock socket.Socket) socket.Result {
+	endpoint := net.JoinHostPort(hostname(sock.Host), strconv.Itoa(sock.Port))
+
+	payload, err := udpPayload(sock)
+	if err != nil {
+		return socket.Result{Socket: sock, Error: err}
+	}
+
+	runner.logger.Debug("UDP runner: send to " + endpoint)
+
+	conn, err := dialContext(ctx, "udp", connectAddress(sock, endpoint))
+	if err != nil {
+		return socket.Result{Socket: sock, Error: err}
+	}
+
+	defer func() {
+		if err := conn.Close(); err != nil {
+			runner.logger.Errorf("failed to close UDP connection to %s: %v", endpoint, err)
+		}
+	}()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return socket.Result{Socket: sock, Error: fmt.Errorf("failed to set a deadline on the UDP connection: %w", err)}
+		}
+	}
+
+	if _, err := conn.Write(payload); err != nil {
+		return socket.Result{Socket: sock, Error: fmt.Errorf("failed to send a UDP datagram: %w", err)}
+	}
+
+	reply := make([]byte, maxUDPPayload)
+
+	n, err := conn.Read(reply)
+	if err != nil {
+		return socket.Result{Socket: sock, Error: fmt.Errorf("failed to receive a UDP reply: %w", err)}
+	}
+
+	if err := matchUDPReply(reply[:n], sock.UDPExpectPrefix, sock.UDPExpectRegex); err != nil {
+		return socket.Result{Socket: sock, Error: err}
+	}
+
+	return socket.Result{Socket: sock, Passed: true}
+}
+
+// udpPayload returns the payload configured for the given socket, decoding it from hex if necessary.
+func udpPayload(sock socket.Socket) ([]byte, error) {
+	if sock.UDPSend != "" && sock.UDPSendHex != "" {
+		return nil, errors.New("only one of udp_send and udp_send_hex can be set")
+	}
+
+	if sock.UDPSendHex != "" {
+		payload, err := hex.DecodeString(sock.UDPSendHex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex payload: %w", err)
+		}
+		return payload, nil
+	}
+
+	return []byte(sock.UDPSend), nil
+}
+
+// matchUDPReply checks the reply against the expected prefix and regular expression, if set.
+func matchUDPReply(reply []byte, prefix, expr string) error {
+	if prefix != "" && !bytes.HasPrefix(reply, []byte(prefix)) {
+		return fmt.Errorf("expected UDP reply to start with %q, got %q", prefix, truncate(reply, 64))
+	}
+
+	if expr != "" {
+		exp, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("invalid reply regex: %w", err)
+		}
+
+		if !exp.Match(reply) {
+			return fmt.Errorf("expected UDP reply to match %q, got %q", expr, truncate(reply, 64))
+		}
+	}
+
+	return nil
+}
+
+// truncate returns at most n first bytes of the provided data.
+func truncate(data []byte, n int) []byte {
+	if len(data) > n {
+		return data[:n]
+	}
+
+	return data
+}
@@ -0,0 +1,116 @@
+package netrunner
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"go.vxn.dev/dish/pkg/socket"
+)
+
+// newTestUDPServer starts a UDP server on a random local port which replies to datagrams starting
+// with "ping" with "pong" followed by the rest of the datagram. Other datagrams are ignored.
+func newTestUDPServer(t *testing.T) int {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start a UDP server: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			if rest, ok := bytes.CutPrefix(buf[:n], []byte("ping")); ok {
+				_, _ = conn.WriteTo(append([]byte("pong"), rest...), addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestUdpRunner_RunTest(t *testing.T) {
+	port := newTestUDPServer(t)
+
+	tests := []struct {
+		name       string
+		sock       socket.Socket
+		wantPassed bool
+	}{
+		{
+			name:       "passes when any reply is received",
+			sock:       socket.Socket{Host: "127.0.0.1", Port: port, UDPSend: "ping"},
+			wantPassed: true,
+		},
+		{
+			name:       "passes on a reply with the expected prefix",
+			sock:       socket.Socket{Host: "127.0.0.1", Port: port, UDPSend: "ping 1", UDPExpectPrefix: "pong"},
+			wantPassed: true,
+		},
+		{
+			name:       "passes on a hex payload and a reply matching the regex",
+			sock:       socket.Socket{Host: "127.0.0.1", Port: port, UDPSendHex: "70696e672031", UDPExpectRegex: `^pong \d$`},
+			wantPassed: true,
+		},
+		{
+			name: "fails on a reply without the expected prefix",
+			sock: socket.Socket{Host: "127.0.0.1", Port: port, UDPSend: "ping", UDPExpectPrefix: "PONG"},
+		},
+		{
+			name: "fails on a reply not matching the regex",
+			sock: socket.Socket{Host: "127.0.0.1", Port: port, UDPSend: "ping", UDPExpectRegex: `^pong \d$`},
+		},
+		{
+			name: "fails when no reply is received",
+			sock: socket.Socket{Host: "127.0.0.1", Port: port, UDPSend: "hello"},
+		},
+		{
+			name: "fails on an invalid hex payload",
+			sock: socket.Socket{Host: "127.0.0.1", Port: port, UDPSendHex: "zz"},
+		},
+		{
+			name: "fails when both a text and a hex payload are set",
+			sock: socket.Socket{Host: "127.0.0.1", Port: port, UDPSend: "ping", UDPSendHex: "70696e67"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			runner := udpRunner{logger: &MockLogger{}}
+
+			got := runner.RunTest(ctx, tt.sock)
+			if got.Passed != tt.wantPassed {
+				t.Fatalf("udpRunner.RunTest(): passed = %v, want %v (error: %v)", got.Passed, tt.wantPassed, got.Error)
+			}
+		})
+	}
+}
+
+func TestNewUDPRunner(t *testing.T) {
+	if _, err := NewNetRunner(socket.Socket{Host: "127.0.0.1", Port: 514, Protocol: "udp"}, &MockLogger{}); err != nil {
+		t.Fatalf("NewNetRunner(): unexpected error: %v", err)
+	}
+
+	if _, err := NewNetRunner(socket.Socket{Host: "127.0.0.1", Protocol: "udp"}, &MockLogger{}); err == nil {
+		t.Fatal("NewNetRunner(): expected an error for a UDP socket without a port")
+	}
+
+	if _, err := NewNetRunner(socket.Socket{Port: 514, Protocol: "udp"}, &MockLogger{}); err == nil {
+		t.Fatal("NewNetRunner(): expected an error for a UDP socket without a host")
+	}
+}
@@ -117,7 +117,11 @@ func (f *fetchHandler) loadFreshSockets(config *config.Config) (io.ReadCloser, e
 		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
 	}
 
-	client := &http.Client{}
+	client, err := newRemoteClient(config)
+	if err != nil {
+		return nil, err
+	}
+
 	req.Header.Set("Content-Type", "application/json")
 
 	if config.ApiHeaderName != "" && config.ApiHeaderValue != "" {
@@ -135,3 +139,21 @@ func (f *fetchHandler) loadFreshSockets(config *config.Config) (io.ReadCloser, e
 
 	return resp.Body, nil
 }
+
+// newRemoteClient returns a HTTP client used to fetch sockets from the remote source. If a proxy is set in the config,
+// the client makes requests through it.
+func newRemoteClient(cfg *config.Config) (*http.Client, error) {
+	if cfg.Proxy == "" {
+		return &http.Client{}, nil
+	}
+
+	proxyURL, err := config.ParseProxyURL(cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxyURL)
+
+	return &http.Client{Transport: transport}, nil
+}
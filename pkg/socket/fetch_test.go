@@ -1,11 +1,14 @@
 package socket
 
 import (
+	"encoding/base64"
 	"errors"
 	"io"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"go.vxn.dev/dish/pkg/config"
@@ -112,3 +115,55 @@ func TestFetchSocketsFromRemote(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadFreshSockets_Proxy(t *testing.T) {
+	// The mock server acts as a proxy requiring auth. The source does not resolve, so the fetch only succeeds if
+	// it is made through the proxy.
+	proxyAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte("dish:s3cret"))
+	proxy := newMockServer(t, "Proxy-Authorization", proxyAuth, testSockets, http.StatusOK)
+
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatalf("failed to parse proxy URL: %v", err)
+	}
+	proxyURL.User = url.UserPassword("dish", "s3cret")
+
+	tests := []struct {
+		name          string
+		proxy         string
+		expectedError bool
+	}{
+		{"Fetch Through Proxy", proxyURL.String(), false},
+		{"Fetch Through Proxy With Wrong Credentials", strings.Replace(proxyURL.String(), "s3cret", "wrong", 1), true},
+		{"Unsupported Proxy Scheme", "ftp://proxy.example.com", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Source: "http://sockets.test/list",
+				Proxy:  tt.proxy,
+			}
+
+			resp, err := NewFetchHandler(&mockLogger{}).loadFreshSockets(cfg)
+			if tt.expectedError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			readBytes, err := io.ReadAll(resp)
+			if err != nil {
+				t.Fatalf("failed to read from response: %v", err)
+			}
+
+			if string(readBytes) != testSockets {
+				t.Errorf("expected %s, got %s", testSockets, string(readBytes))
+			}
+		})
+	}
+}